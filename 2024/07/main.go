@@ -3,12 +3,16 @@ package main
 import (
 	"bufio"
 	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"math/big"
 	"os"
 	"slices"
 	"strconv"
 	"strings"
+
+	"github.com/TonyRippy/advent-of-code/2024/checked"
 )
 
 type Equation struct {
@@ -16,11 +20,9 @@ type Equation struct {
 	Args []int
 }
 
-func (eq *Equation) Check(f func(int, []int) bool) bool {
-	args := make([]int, len(eq.Args))
-	copy(args, eq.Args)
-	slices.Reverse(args)
-	return f(eq.TestValue, args)
+// Solve returns an expression for the equation that uses the given operators.
+func (eq *Equation) Solve(ops []Operator) (Expr, bool) {
+	return Solve(eq.TestValue, eq.Args, ops)
 }
 
 func parseInput(filename string) ([]*Equation, error) {
@@ -41,6 +43,9 @@ func parseInput(filename string) ([]*Equation, error) {
 		eq := &Equation{}
 		eq.TestValue, err = strconv.Atoi(line[:i])
 		if err != nil {
+			if errors.Is(err, strconv.ErrRange) {
+				return nil, fmt.Errorf("test value %q: %w", line[:i], checked.ErrOverflow)
+			}
 			return nil, fmt.Errorf("invalid test value: %q", line[:i])
 		}
 		line = line[i+1:]
@@ -52,6 +57,9 @@ func parseInput(filename string) ([]*Equation, error) {
 		for i, f := range fields {
 			eq.Args[i], err = strconv.Atoi(strings.TrimSpace(f))
 			if err != nil {
+				if errors.Is(err, strconv.ErrRange) {
+					return nil, fmt.Errorf("list item %q: %w", f, checked.ErrOverflow)
+				}
 				return nil, fmt.Errorf("invalid list item: %q", f)
 			}
 		}
@@ -64,33 +72,97 @@ func parseInput(filename string) ([]*Equation, error) {
   return eqs, nil
 }
 
-func CheckPart1(tv int, args []int) bool {
-	// if len(args) == 0 {
-	// 	return false
-	// }
-	arg := args[0]
-	if len(args) == 1 {
-		return tv == arg
+// Operator is a binary operator that can be placed between the arguments of
+// an equation. Operators are always evaluated left-to-right.
+type Operator interface {
+	// Apply returns the result of lhs <op> rhs, or checked.ErrOverflow if
+	// it doesn't fit in an int.
+	Apply(lhs, rhs int) (int, error)
+	// ApplyBig is the same as Apply, but using arbitrary precision.
+	ApplyBig(lhs, rhs *big.Int) *big.Int
+	// Unapply returns the lhs that satisfies lhs <op> rhs == result, if any.
+	// This lets the checker work backwards from the test value and prune
+	// operators that can't possibly produce it. Working backwards only makes
+	// values smaller, so Unapply has no need for a big.Int version.
+	Unapply(result, rhs int) (int, bool)
+	String() string
+}
+
+type Add struct{}
+
+func (Add) Apply(lhs, rhs int) (int, error) {
+	return checked.Add(lhs, rhs)
+}
+
+func (Add) ApplyBig(lhs, rhs *big.Int) *big.Int {
+	return new(big.Int).Add(lhs, rhs)
+}
+
+func (Add) Unapply(result, rhs int) (int, bool) {
+	lhs, err := checked.Sub(result, rhs)
+	return lhs, err == nil
+}
+
+func (Add) String() string {
+	return "+"
+}
+
+type Multiply struct{}
+
+func (Multiply) Apply(lhs, rhs int) (int, error) {
+	return checked.Mul(lhs, rhs)
+}
+
+func (Multiply) ApplyBig(lhs, rhs *big.Int) *big.Int {
+	return new(big.Int).Mul(lhs, rhs)
+}
+
+func (Multiply) Unapply(result, rhs int) (int, bool) {
+	if rhs == 0 || result%rhs != 0 {
+		return 0, false
 	}
-	// Can multiplication be used?
-	if tv % arg == 0 {
-		// Try solution
-		if CheckPart1(tv / arg, args[1:]) {
-			return true
+	return result / rhs, true
+}
+
+func (Multiply) String() string {
+	return "*"
+}
+
+type Concat struct{}
+
+func (Concat) Apply(lhs, rhs int) (int, error) {
+	shift := 10
+	for shift <= rhs {
+		var err error
+		if shift, err = checked.Mul(shift, 10); err != nil {
+			return 0, err
 		}
 	}
-	// Can addition be used?
-	return CheckPart1(tv - arg, args[1:])
+	v, err := checked.Mul(lhs, shift)
+	if err != nil {
+		return 0, err
+	}
+	return checked.Add(v, rhs)
 }
 
-func Part1(eqs []*Equation) int {
-	var sum int
-	for _, eq := range eqs {
-		if CheckPart1(eq.TestValue, eq.Args) {
-			sum += eq.TestValue
-		}
+func (Concat) ApplyBig(lhs, rhs *big.Int) *big.Int {
+	shift := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(len(rhs.String()))), nil)
+	v := new(big.Int).Mul(lhs, shift)
+	return v.Add(v, rhs)
+}
+
+func (Concat) Unapply(result, rhs int) (int, bool) {
+	// Working backwards with Add can give a negative target, but
+	// concatenating numbers that aren't negative never does. Without this
+	// a target of -5 would leave a prefix of "-".
+	if result < 0 || rhs < 0 {
+		return 0, false
 	}
-	return sum
+	return isSuffixOf(rhs, result)
+}
+
+func (Concat) String() string {
+	return "||"
 }
 
 // Returns the prefix of value after suffix removed
@@ -105,48 +177,274 @@ func isSuffixOf(suffix, value int) (int, bool) {
 	return prefix, true
 }
 
-func CheckPart2(tv int, args []int) bool {
-	// if len(args) == 0 {
-	// 	return false
-	// }
+// The operators allowed in each part of the puzzle, in the order they are tried.
+var (
+	Part1Ops = []Operator{Multiply{}, Add{}}
+	Part2Ops = []Operator{Multiply{}, Add{}, Concat{}}
+)
+
+// Expr is a node in the expression tree of a solved equation.
+type Expr interface {
+	Eval() (int, error)
+	EvalBig() *big.Int
+	String() string
+}
+
+type Value int
+
+func (v Value) Eval() (int, error) {
+	return int(v), nil
+}
+
+func (v Value) EvalBig() *big.Int {
+	return big.NewInt(int64(v))
+}
+
+func (v Value) String() string {
+	return strconv.Itoa(int(v))
+}
+
+type BinaryExpr struct {
+	Op          Operator
+	Left, Right Expr
+}
+
+func (e *BinaryExpr) Eval() (int, error) {
+	lhs, err := e.Left.Eval()
+	if err != nil {
+		return 0, err
+	}
+	rhs, err := e.Right.Eval()
+	if err != nil {
+		return 0, err
+	}
+	return e.Op.Apply(lhs, rhs)
+}
+
+func (e *BinaryExpr) EvalBig() *big.Int {
+	return e.Op.ApplyBig(e.Left.EvalBig(), e.Right.EvalBig())
+}
+
+func (e *BinaryExpr) String() string {
+	operand := func(x Expr) string {
+		if _, ok := x.(*BinaryExpr); ok {
+			return "(" + x.String() + ")"
+		}
+		return x.String()
+	}
+	return fmt.Sprintf("%s %s %s", operand(e.Left), e.Op, operand(e.Right))
+}
+
+// Solve looks for a way to combine args with ops that produces tv.
+// The args are expected in reverse order, last argument first.
+func Solve(tv int, args []int, ops []Operator) (Expr, bool) {
 	arg := args[0]
 	if len(args) == 1 {
-		return tv == arg
+		if tv != arg {
+			return nil, false
+		}
+		return Value(arg), true
+	}
+	for _, op := range ops {
+		lhs, ok := op.Unapply(tv, arg)
+		if !ok {
+			continue
+		}
+		if left, ok := Solve(lhs, args[1:], ops); ok {
+			return &BinaryExpr{op, left, Value(arg)}, true
+		}
+	}
+	return nil, false
+}
+
+// memoKey identifies a sub-problem: producing tv from the first n arguments.
+type memoKey struct {
+	tv, n int
+}
+
+// Count returns the number of operator assignments that combine args into tv.
+// Unlike Solve it doesn't stop at the first match, so sub-problems are
+// memoised to keep long equations tractable.
+// The args are expected in reverse order, last argument first.
+func Count(tv int, args []int, ops []Operator) int {
+	return count(tv, args, ops, make(map[memoKey]int))
+}
+
+func count(tv int, args []int, ops []Operator, memo map[memoKey]int) int {
+	arg := args[0]
+	if len(args) == 1 {
+		if tv != arg {
+			return 0
+		}
+		return 1
+	}
+	key := memoKey{tv, len(args)}
+	if n, ok := memo[key]; ok {
+		return n
+	}
+	var n int
+	for _, op := range ops {
+		if lhs, ok := op.Unapply(tv, arg); ok {
+			n += count(lhs, args[1:], ops, memo)
+		}
 	}
-	// Can multiplication be used?
-	if tv % arg == 0 {
-		// Try solution
-		if CheckPart2(tv / arg, args[1:]) {
-			return true
+	memo[key] = n
+	return n
+}
+
+// Solutions returns an expression for every operator assignment that
+// combines args into tv. Sub-expressions are shared between solutions.
+// The args are expected in reverse order, last argument first.
+func Solutions(tv int, args []int, ops []Operator) []Expr {
+	return solutions(tv, args, ops, make(map[memoKey][]Expr))
+}
+
+func solutions(tv int, args []int, ops []Operator, memo map[memoKey][]Expr) []Expr {
+	arg := args[0]
+	if len(args) == 1 {
+		if tv != arg {
+			return nil
 		}
+		return []Expr{Value(arg)}
 	}
-	// Can addition be used?
-	if CheckPart2(tv - arg, args[1:]) {
-		return true
+	key := memoKey{tv, len(args)}
+	if exprs, ok := memo[key]; ok {
+		return exprs
 	}
-	// Last resort, try concatenation
-	if prefix, ok := isSuffixOf(arg, tv); ok {
-		return CheckPart2(prefix, args[1:])
+	var exprs []Expr
+	for _, op := range ops {
+		lhs, ok := op.Unapply(tv, arg)
+		if !ok {
+			continue
+		}
+		for _, left := range solutions(lhs, args[1:], ops, memo) {
+			exprs = append(exprs, &BinaryExpr{op, left, Value(arg)})
+		}
 	}
-	return false
+	memo[key] = exprs
+	return exprs
+}
+
+// Count returns the number of ways the equation can be solved with ops.
+func (eq *Equation) Count(ops []Operator) int {
+	return Count(eq.TestValue, eq.Args, ops)
+}
+
+// Solutions returns every way the equation can be solved with ops.
+func (eq *Equation) Solutions(ops []Operator) []Expr {
+	return Solutions(eq.TestValue, eq.Args, ops)
 }
 
+// Ambiguous returns the equations that can be solved in more than one way.
+func Ambiguous(eqs []*Equation, ops []Operator) []*Equation {
+	var out []*Equation
+	for _, eq := range eqs {
+		if eq.Count(ops) > 1 {
+			out = append(out, eq)
+		}
+	}
+	return out
+}
 
-func Part2(eqs []*Equation) int {
+// Total returns the sum of the test values of all equations that can be
+// solved with ops. It returns checked.ErrOverflow if the sum doesn't fit in an
+// int, in which case TotalBig can be used instead.
+func Total(eqs []*Equation, ops []Operator) (int, error) {
 	var sum int
 	for _, eq := range eqs {
-		if CheckPart2(eq.TestValue, eq.Args) {
-			sum += eq.TestValue
+		if _, ok := eq.Solve(ops); !ok {
+			continue
+		}
+		var err error
+		if sum, err = checked.Add(sum, eq.TestValue); err != nil {
+			return 0, err
+		}
+	}
+	return sum, nil
+}
+
+func TotalBig(eqs []*Equation, ops []Operator) *big.Int {
+	sum := new(big.Int)
+	for _, eq := range eqs {
+		if _, ok := eq.Solve(ops); ok {
+			sum.Add(sum, big.NewInt(int64(eq.TestValue)))
 		}
 	}
 	return sum
 }
 
+func CheckPart1(tv int, args []int) bool {
+	_, ok := Solve(tv, args, Part1Ops)
+	return ok
+}
+
+func Part1(eqs []*Equation) (int, error) {
+	return Total(eqs, Part1Ops)
+}
+
+func CheckPart2(tv int, args []int) bool {
+	_, ok := Solve(tv, args, Part2Ops)
+	return ok
+}
+
+func Part2(eqs []*Equation) (int, error) {
+	return Total(eqs, Part2Ops)
+}
+
+// printTotal prints the total for ops, switching to big arithmetic if asked
+// to or if the sum overflows.
+func printTotal(name string, eqs []*Equation, ops []Operator, useBig bool) {
+	if !useBig {
+		sum, err := Total(eqs, ops)
+		if err == nil {
+			fmt.Printf("%s: %d\n", name, sum)
+			return
+		}
+		if !errors.Is(err, checked.ErrOverflow) {
+			log.Fatal(err)
+		}
+		log.Printf("%s: %v, switching to big arithmetic", name, err)
+	}
+	fmt.Printf("%s: %s\n", name, TotalBig(eqs, ops))
+}
+
 func main() {
-	eqs, err := parseInput(os.Args[1])
+	verbose := flag.Bool("v", false, "print the solution found for each equation")
+	countAll := flag.Bool("count", false, "print the number of solutions for each equation")
+	listAll := flag.Bool("list", false, "print every solution for each equation")
+	useBig := flag.Bool("big", false, "use arbitrary precision arithmetic")
+	flag.Parse()
+
+	eqs, err := parseInput(flag.Arg(0))
 	if err != nil {
 		log.Fatal(err)
 	}
-	fmt.Printf("Part1: %d\n", Part1(eqs))
-	fmt.Printf("Part2: %d\n", Part2(eqs))
+	if *verbose {
+		for _, eq := range eqs {
+			if expr, ok := eq.Solve(Part2Ops); ok {
+				fmt.Printf("%d = %s\n", eq.TestValue, expr)
+			} else {
+				fmt.Printf("%d: no solution\n", eq.TestValue)
+			}
+		}
+	}
+	if *countAll {
+		for _, eq := range eqs {
+			fmt.Printf("%d: %d ways\n", eq.TestValue, eq.Count(Part2Ops))
+		}
+		ambiguous := Ambiguous(eqs, Part2Ops)
+		fmt.Printf("Ambiguous: %d of %d equations\n", len(ambiguous), len(eqs))
+		for _, eq := range ambiguous {
+			fmt.Printf("  %d: %d ways\n", eq.TestValue, eq.Count(Part2Ops))
+		}
+	}
+	if *listAll {
+		for _, eq := range eqs {
+			for _, expr := range eq.Solutions(Part2Ops) {
+				fmt.Printf("%d = %s\n", eq.TestValue, expr)
+			}
+		}
+	}
+	printTotal("Part1", eqs, Part1Ops, *useBig)
+	printTotal("Part2", eqs, Part2Ops, *useBig)
 }
@@ -1,9 +1,13 @@
 package main
 
 import (
+	"fmt"
+	"math"
+	"math/big"
 	"slices"
 	"testing"
 
+	"github.com/TonyRippy/advent-of-code/2024/checked"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -34,11 +38,16 @@ func TestSuffix(t *testing.T) {
 func TestPart1(t *testing.T) {
 	eqs, err := parseInput("test.txt")
 	require.NoError(t, err)
-	assert.Equal(t, 3749, Part1(eqs))
+	sum, err := Part1(eqs)
+	require.NoError(t, err)
+	assert.Equal(t, 3749, sum)
 
 	eqs, err = parseInput("input.txt")
 	require.NoError(t, err)
-	assert.Equal(t, 3312271365652, Part1(eqs))
+	sum, err = Part1(eqs)
+	require.NoError(t, err)
+	assert.Equal(t, 3312271365652, sum)
+	assert.Equal(t, big.NewInt(3312271365652), TotalBig(eqs, Part1Ops))
 }
 
 func TestCheckPart2(t *testing.T) {
@@ -54,14 +63,195 @@ func TestCheckPart2(t *testing.T) {
 	raw := []int{6, 8, 6, 15}
 	slices.Reverse(raw)
 	assert.True(t, CheckPart2(7290, raw))
+
+	expr, ok := Solve(7290, raw, Part2Ops)
+	require.True(t, ok)
+	assert.Equal(t, "((6 * 8) || 6) * 15", expr.String())
+	v, err := expr.Eval()
+	require.NoError(t, err)
+	assert.Equal(t, 7290, v)
+
+	_, ok = Solve(7290, raw, Part1Ops)
+	assert.False(t, ok)
+}
+
+func TestSolve(t *testing.T) {
+	for _, tc := range []struct {
+		ops  []Operator
+		want int
+	}{
+		{Part1Ops, 3312271365652},
+		{Part2Ops, 509463489296712},
+	} {
+		t.Run(fmt.Sprint(tc.ops), func(t *testing.T) {
+			eqs, err := parseInput("input.txt")
+			require.NoError(t, err)
+			var sum int
+			for _, eq := range eqs {
+				expr, ok := eq.Solve(tc.ops)
+				if !ok {
+					continue
+				}
+				v, err := expr.Eval()
+				require.NoError(t, err)
+				require.Equal(t, eq.TestValue, v, expr.String())
+				sum += eq.TestValue
+			}
+			assert.Equal(t, tc.want, sum)
+		})
+	}
 }
 
 func TestPart2(t *testing.T) {
 	eqs, err := parseInput("test.txt")
 	require.NoError(t, err)
-	assert.Equal(t, 11387, Part2(eqs))
+	sum, err := Part2(eqs)
+	require.NoError(t, err)
+	assert.Equal(t, 11387, sum)
 
 	eqs, err = parseInput("input.txt")
 	require.NoError(t, err)
-	assert.Equal(t, 509463489296712, Part2(eqs))
+	sum, err = Part2(eqs)
+	require.NoError(t, err)
+	assert.Equal(t, 509463489296712, sum)
+	assert.Equal(t, big.NewInt(509463489296712), TotalBig(eqs, Part2Ops))
+}
+
+func TestOverflow(t *testing.T) {
+	// MaxInt + 1 doesn't fit in the sum.
+	eqs := []*Equation{
+		{TestValue: math.MaxInt, Args: []int{0, math.MaxInt}},
+		{TestValue: 1, Args: []int{1}},
+	}
+	_, err := Total(eqs, Part1Ops)
+	assert.ErrorIs(t, err, checked.ErrOverflow)
+	want, _ := new(big.Int).SetString("9223372036854775808", 10)
+	assert.Equal(t, want, TotalBig(eqs, Part1Ops))
+
+	// Concatenating the Part 2 answer with itself needs about 100 bits.
+	expr := &BinaryExpr{Concat{}, Value(509463489296712), Value(509463489296712)}
+	_, err = expr.Eval()
+	assert.ErrorIs(t, err, checked.ErrOverflow)
+	want, _ = new(big.Int).SetString("509463489296712509463489296712", 10)
+	assert.Equal(t, want, expr.EvalBig())
+}
+
+func FuzzApply(f *testing.F) {
+	f.Add(6, 8)
+	f.Add(486, 15)
+	f.Add(509463489296712, 1000)
+	f.Add(math.MaxInt, 0)
+	f.Add(-5, 5)
+	f.Fuzz(func(t *testing.T, lhs, rhs int) {
+		for _, op := range Part2Ops {
+			// Working backwards can reach any target, even a negative
+			// one, but whatever Unapply returns has to give the target
+			// back.
+			if prev, ok := op.Unapply(lhs, rhs); ok {
+				got, err := op.Apply(prev, rhs)
+				require.NoError(t, err, op.String())
+				assert.Equal(t, lhs, got, "%d %s %d", prev, op, rhs)
+			}
+			if lhs < 0 || rhs < 0 {
+				// Puzzle inputs are never negative, and concatenation
+				// isn't well defined for negative numbers.
+				continue
+			}
+			want := op.ApplyBig(big.NewInt(int64(lhs)), big.NewInt(int64(rhs)))
+			got, err := op.Apply(lhs, rhs)
+			if want.IsInt64() {
+				require.NoError(t, err, op.String())
+				assert.Equal(t, want.Int64(), int64(got), op.String())
+			} else {
+				assert.ErrorIs(t, err, checked.ErrOverflow, op.String())
+			}
+			if err != nil {
+				continue
+			}
+			// Undoing the operation gets us back to where we started.
+			prev, ok := op.Unapply(got, rhs)
+			if rhs != 0 || op.String() != "*" {
+				assert.True(t, ok, op.String())
+				assert.Equal(t, lhs, prev, op.String())
+			}
+		}
+	})
+}
+
+func TestZeros(t *testing.T) {
+	for _, tc := range []struct {
+		eq    Equation
+		count int
+	}{
+		{Equation{5, []int{0, 5, 10}}, 0},
+		{Equation{5, []int{0, 5}}, 2},
+		{Equation{10, []int{1, 0}}, 1},
+		{Equation{50, []int{5, 0, 0}}, 2},
+		{Equation{15, []int{0, 5, 10}}, 2},
+	} {
+		t.Run(fmt.Sprint(tc.eq), func(t *testing.T) {
+			eq := tc.eq
+			eq.Args = slices.Clone(eq.Args)
+			slices.Reverse(eq.Args)
+			expr, ok := eq.Solve(Part2Ops)
+			assert.Equal(t, tc.count > 0, ok)
+			if ok {
+				v, err := expr.Eval()
+				require.NoError(t, err)
+				assert.Equal(t, eq.TestValue, v, expr.String())
+			}
+			assert.Equal(t, tc.count, eq.Count(Part2Ops))
+			solutions := eq.Solutions(Part2Ops)
+			assert.Len(t, solutions, tc.count)
+			for _, expr := range solutions {
+				v, err := expr.Eval()
+				require.NoError(t, err)
+				assert.Equal(t, eq.TestValue, v, expr.String())
+			}
+		})
+	}
+}
+
+func TestCount(t *testing.T) {
+	eqs, err := parseInput("test.txt")
+	require.NoError(t, err)
+	var got []int
+	for _, eq := range eqs {
+		got = append(got, eq.Count(Part2Ops))
+	}
+	assert.Equal(t, []int{1, 2, 0, 1, 1, 0, 1, 0, 1}, got)
+
+	ambiguous := Ambiguous(eqs, Part2Ops)
+	require.Len(t, ambiguous, 1)
+	assert.Equal(t, 3267, ambiguous[0].TestValue)
+
+	var exprs []string
+	for _, expr := range ambiguous[0].Solutions(Part2Ops) {
+		exprs = append(exprs, expr.String())
+	}
+	assert.ElementsMatch(t, []string{"(81 + 40) * 27", "(81 * 40) + 27"}, exprs)
+
+	// 8 = (2 + 2) * 2 = (2 * 2) * 2
+	assert.Equal(t, 2, Count(8, []int{2, 2, 2}, Part2Ops))
+	// 22 = 2 || 2, 222 = (2 || 2) || 2
+	assert.Equal(t, 1, Count(222, []int{2, 2, 2}, Part2Ops))
+}
+
+func TestSolutions(t *testing.T) {
+	eqs, err := parseInput("input.txt")
+	require.NoError(t, err)
+	for _, eq := range eqs {
+		exprs := eq.Solutions(Part2Ops)
+		_, ok := eq.Solve(Part2Ops)
+		require.Equal(t, ok, len(exprs) > 0)
+		require.Len(t, exprs, eq.Count(Part2Ops))
+		seen := make(map[string]bool)
+		for _, expr := range exprs {
+			v, err := expr.Eval()
+			require.NoError(t, err)
+			require.Equal(t, eq.TestValue, v, expr.String())
+			require.False(t, seen[expr.String()], expr.String())
+			seen[expr.String()] = true
+		}
+	}
 }
@@ -2,10 +2,14 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
+	"io"
 	"iter"
 	"log"
+	"maps"
 	"os"
+	"slices"
 	"strings"
 )
 
@@ -23,7 +27,6 @@ type Map struct {
 	Lines    []string
 	Antennas AntennaLists
 	W, H     int
-	debug    bool
 }
 
 func (m *Map) InBounds(p Point) bool {
@@ -50,8 +53,11 @@ func parseInput(filename string) (*Map, error) {
 		return nil, err
 	}
 	defer file.Close()
+	return parse(file)
+}
 
-	scanner := bufio.NewScanner(file)
+func parse(r io.Reader) (*Map, error) {
+	scanner := bufio.NewScanner(r)
 	if !scanner.Scan() {
 		return nil, fmt.Errorf("missing first line")
 	}
@@ -106,28 +112,114 @@ func antinodes1(_ *Map, p1 Point, p2 Point) iter.Seq[Point] {
 	}
 }
 
-func (m *Map) Part1() int {
-	var count int
-	seen := make(map[Point]bool)
-	for _, alist := range m.Antennas {
+// antinodeFunc returns the antinodes created by a pair of antennas.
+type antinodeFunc func(m *Map, p1 Point, p2 Point) iter.Seq[Point]
+
+// findAntinodes returns the unique locations on the map that contain an
+// antinode, along with the frequencies that create each one.
+func (m *Map) findAntinodes(antinodes antinodeFunc) map[Point][]rune {
+	found := make(map[Point][]rune)
+	for freq, alist := range m.Antennas {
+		seen := make(map[Point]bool)
 		for i, j := range combinations(len(alist)) {
 			p1 := alist[i]
 			p2 := alist[j]
-			for antinode := range antinodes1(m, p1, p2) {
+			for antinode := range antinodes(m, p1, p2) {
 				// Is this point on the map?
 				if !m.InBounds(antinode) {
 					continue
 				}
-				// Check if this point is already an antinode
+				// Check if this frequency already has an antinode here
 				if _, ok := seen[antinode]; ok {
 					continue
 				}
 				seen[antinode] = true
-				count++
+				found[antinode] = append(found[antinode], freq)
 			}
 		}
 	}
-	return count
+	return found
+}
+
+// countAntinodes returns the number of unique locations on the map that
+// contain an antinode.
+func (m *Map) countAntinodes(antinodes antinodeFunc) int {
+	return len(m.findAntinodes(antinodes))
+}
+
+// FrequencyReport describes the antinodes created by a single frequency.
+type FrequencyReport struct {
+	Frequency rune
+	Antennas  int
+	Antinodes int
+	// Shared counts the antinodes that are also created by other
+	// frequencies, keyed by the other frequency.
+	Shared map[rune]int
+}
+
+func (r FrequencyReport) String() string {
+	var s strings.Builder
+	fmt.Fprintf(&s, "%c: %d antennas, %d antinodes", r.Frequency, r.Antennas, r.Antinodes)
+	for _, other := range slices.Sorted(maps.Keys(r.Shared)) {
+		fmt.Fprintf(&s, ", %d shared with %c", r.Shared[other], other)
+	}
+	return s.String()
+}
+
+// Report breaks down the antinodes on the map by frequency, sorted by
+// frequency.
+func (m *Map) Report(antinodes antinodeFunc) []FrequencyReport {
+	reports := make(map[rune]*FrequencyReport)
+	for freq, alist := range m.Antennas {
+		reports[freq] = &FrequencyReport{
+			Frequency: freq,
+			Antennas:  len(alist),
+			Shared:    make(map[rune]int),
+		}
+	}
+	for _, freqs := range m.findAntinodes(antinodes) {
+		for _, freq := range freqs {
+			r := reports[freq]
+			r.Antinodes++
+			for _, other := range freqs {
+				if other != freq {
+					r.Shared[other]++
+				}
+			}
+		}
+	}
+	var out []FrequencyReport
+	for _, freq := range slices.Sorted(maps.Keys(reports)) {
+		out = append(out, *reports[freq])
+	}
+	return out
+}
+
+// Render draws the map the way the puzzle does, with antinodes marked as '#'.
+// Antennas are drawn on top of any antinodes in the same location.
+func (m *Map) Render(antinodes antinodeFunc) string {
+	grid := make([][]rune, m.H)
+	for y := range grid {
+		grid[y] = []rune(strings.Repeat(".", m.W))
+	}
+	for p := range m.findAntinodes(antinodes) {
+		grid[p.Y][p.X] = '#'
+	}
+	for freq, alist := range m.Antennas {
+		for _, p := range alist {
+			grid[p.Y][p.X] = freq
+		}
+	}
+	var s strings.Builder
+	for _, row := range grid {
+		s.WriteString(string(row))
+		s.WriteRune('\n')
+	}
+	return s.String()
+}
+
+func (m *Map) Part1() int {
+	return m.countAntinodes(antinodes1)
 }
 
 func antinodes2(m *Map, p1 Point, p2 Point) iter.Seq[Point] {
@@ -158,33 +250,82 @@ func antinodes2(m *Map, p1 Point, p2 Point) iter.Seq[Point] {
 }
 
 func (m *Map) Part2() int {
-	var count int
-	seen := make(map[Point]bool)
-	for _, alist := range m.Antennas {
-		for i, j := range combinations(len(alist)) {
-			p1 := alist[i]
-			p2 := alist[j]
-			for antinode := range antinodes2(m, p1, p2) {
-				// Is this point on the map?
-				if !m.InBounds(antinode) {
-					continue
-				}
-				if _, ok := seen[antinode]; ok {
-					continue
-				}
-				seen[antinode] = true
-				count++
+	return m.countAntinodes(antinodes2)
+}
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	if a < 0 {
+		return -a
+	}
+	return a
+}
+
+// antinodesExact is like antinodes2, but finds every grid point that is
+// exactly in line with the two antennas. Stepping by the raw offset skips
+// points in between when dx and dy have a common divisor, so the step is
+// reduced by their GCD first.
+func antinodesExact(m *Map, p1 Point, p2 Point) iter.Seq[Point] {
+	return func(yield func(Point) bool) {
+		g := gcd(p2.X-p1.X, p2.Y-p1.Y)
+		dx := (p2.X - p1.X) / g
+		dy := (p2.Y - p1.Y) / g
+		// Walk backwards from p1 to the edge of the map, then forwards
+		// through p2 to the opposite edge.
+		p := p1
+		for {
+			prev := Point{p.Y - dy, p.X - dx}
+			if !m.InBounds(prev) {
+				break
+			}
+			p = prev
+		}
+		for m.InBounds(p) {
+			if !yield(p) {
+				return
 			}
+			p = Point{p.Y + dy, p.X + dx}
 		}
 	}
-	return count
+}
+
+// Part2Exact is Part2 using strict geometry: any grid point exactly in line
+// with two antennas of the same frequency counts, not just those at a
+// multiple of the distance between them.
+func (m *Map) Part2Exact() int {
+	return m.countAntinodes(antinodesExact)
+}
+
+// printDebug prints the map with its antinodes, and a breakdown by
+// frequency.
+func printDebug(m *Map, antinodes antinodeFunc) {
+	fmt.Print(m.Render(antinodes))
+	for _, r := range m.Report(antinodes) {
+		fmt.Println(r)
+	}
 }
 
 func main() {
-	m, err := parseInput(os.Args[1])
+	exact := flag.Bool("exact", false, "include every grid point in line with two antennas in Part 2")
+	debug := flag.Bool("debug", false, "print the map and a breakdown by frequency")
+	flag.Parse()
+
+	m, err := parseInput(flag.Arg(0))
 	if err != nil {
 		log.Fatal(err)
 	}
+	part2, antinodes := m.Part2, antinodeFunc(antinodes2)
+	if *exact {
+		part2, antinodes = m.Part2Exact, antinodesExact
+	}
+	if *debug {
+		printDebug(m, antinodes1)
+	}
 	fmt.Printf("Part 1: %d\n", m.Part1())
-	fmt.Printf("Part 2: %d\n", m.Part2())
+	if *debug {
+		printDebug(m, antinodes)
+	}
+	fmt.Printf("Part 2: %d\n", part2())
 }
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"os"
 	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -63,3 +65,122 @@ func TestPart2(t *testing.T) {
 		})
 	}
 }
+
+func TestPart2Exact(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		in     string
+		puzzle int
+		exact  int
+	}{
+		{
+			// The offset (1, 3) is already reduced, so both agree.
+			name: "coprime",
+			in: `T....
+...T.
+.....`,
+			puzzle: 2,
+			exact:  2,
+		},
+		{
+			// (2, 4) has a GCD of 2, so (1, 2) is also in line.
+			name: "gcd2",
+			in: `a....
+.....
+....a`,
+			puzzle: 2,
+			exact:  3,
+		},
+		{
+			// (3, 3) has a GCD of 3, so the whole diagonal is in line.
+			name: "gcd3",
+			in: `.......
+.A.....
+.......
+.......
+....A..
+.......
+.......`,
+			puzzle: 2,
+			exact:  7,
+		},
+		{
+			// Lines from different frequencies cross at (2, 2).
+			name: "overlap",
+			in: `a...b
+.....
+.....
+.....
+b...a`,
+			puzzle: 4,
+			exact:  9,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			m, err := parse(strings.NewReader(tc.in))
+			require.NoError(t, err)
+			assert.Equal(t, tc.puzzle, m.Part2())
+			assert.Equal(t, tc.exact, m.Part2Exact())
+		})
+	}
+
+	// The real puzzle input never has a common divisor.
+	m, err := parseInput("input.txt")
+	require.NoError(t, err)
+	assert.Equal(t, m.Part2(), m.Part2Exact())
+}
+
+func TestRender(t *testing.T) {
+	for _, tc := range []struct {
+		in        string
+		antinodes antinodeFunc
+		want      string
+	}{
+		// test1a.txt doesn't have the antinodes marked.
+		{"test1a.txt", antinodes1, `..........
+...#......
+..........
+....a.....
+..........
+.....a....
+..........
+......#...
+..........
+..........
+`},
+		// The other examples match the puzzle description.
+		{"test1b.txt", antinodes1, ""},
+		{"test1c.txt", antinodes1, ""},
+		{"test1d.txt", antinodes1, ""},
+		{"test2a.txt", antinodes2, ""},
+	} {
+		t.Run(tc.in, func(t *testing.T) {
+			m, err := parseInput(tc.in)
+			require.NoError(t, err)
+			want := tc.want
+			if want == "" {
+				b, err := os.ReadFile(tc.in)
+				require.NoError(t, err)
+				want = strings.TrimSpace(string(b)) + "\n"
+			}
+			assert.Equal(t, want, m.Render(tc.antinodes))
+		})
+	}
+}
+
+func TestReport(t *testing.T) {
+	m, err := parseInput("test1d.txt")
+	require.NoError(t, err)
+	assert.Equal(t, []FrequencyReport{
+		{'0', 4, 10, map[rune]int{'A': 1}},
+		{'A', 3, 5, map[rune]int{'0': 1}},
+	}, m.Report(antinodes1))
+
+	// Antinodes in the same place are only counted once in the total.
+	var sum int
+	for _, r := range m.Report(antinodes2) {
+		sum += r.Antinodes - r.Shared['0']
+	}
+	assert.Equal(t, m.Part2(), sum)
+	assert.Equal(t, "A: 3 antennas, 16 antinodes, 3 shared with 0", m.Report(antinodes2)[1].String())
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Stats describes the state of a disk after it has been compacted.
+type Stats struct {
+	// Moves is the number of pieces of files that moved. A file that moves
+	// whole counts once. A file that is split counts once for each piece
+	// that ends up outside the blocks the file started in; a piece left
+	// behind in those blocks hasn't moved.
+	Moves int
+	// FreeSpans is the number of free spans left between files.
+	FreeSpans int
+	// Fragmentation is the fraction of free blocks that are trapped between
+	// files rather than at the end of the disk. It is 0 for a fully
+	// compacted disk.
+	Fragmentation float64
+}
+
+func (s Stats) String() string {
+	return fmt.Sprintf("%d moves, %d free spans, %.3f fragmentation", s.Moves, s.FreeSpans, s.Fragmentation)
+}
+
+// measure compares a disk before and after compaction.
+func measure(before, after []*Range) Stats {
+	var stats Stats
+
+	// Not every strategy keeps Pos up to date, so work out positions from
+	// the sizes of the ranges instead.
+	type span struct{ start, end int }
+	orig := make(map[int]span)
+	var pos int
+	for _, r := range before {
+		if !r.Free {
+			orig[r.File] = span{pos, pos + r.Size}
+		}
+		pos += r.Size
+	}
+
+	var lastFile, trapped, total int
+	pos = 0
+	for _, r := range after {
+		if r.Free {
+			total += r.Size
+		} else if r.Size > 0 {
+			if s := orig[r.File]; pos < s.start || pos+r.Size > s.end {
+				stats.Moves++
+			}
+			lastFile = pos + r.Size
+		}
+		pos += r.Size
+	}
+	pos = 0
+	inSpan := false
+	for _, r := range after {
+		if pos >= lastFile {
+			break
+		}
+		if r.Free && r.Size > 0 {
+			trapped += r.Size
+			if !inSpan {
+				stats.FreeSpans++
+			}
+			inSpan = true
+		} else if r.Size > 0 {
+			inSpan = false
+		}
+		pos += r.Size
+	}
+	if total > 0 {
+		stats.Fragmentation = float64(trapped) / float64(total)
+	}
+	return stats
+}
+
+// cloneRanges returns a deep copy of ranges, for strategies that update
+// ranges in place.
+func cloneRanges(ranges []*Range) []*Range {
+	out := make([]*Range, len(ranges))
+	for i, r := range ranges {
+		c := *r
+		out[i] = &c
+	}
+	return out
+}
+
+// Compactor is a strategy for moving files towards the start of a disk.
+// Compact must not modify its input.
+type Compactor interface {
+	Name() string
+	Compact(ranges []*Range) ([]*Range, Stats)
+}
+
+// BlockCompactor moves individual blocks, splitting files as needed. This is
+// the strategy from Part 1.
+type BlockCompactor struct{}
+
+func (BlockCompactor) Name() string {
+	return "block"
+}
+
+func (BlockCompactor) Compact(ranges []*Range) ([]*Range, Stats) {
+	out := defrag1(cloneRanges(ranges))
+	return out, measure(ranges, out)
+}
+
+// FirstFit moves each whole file to the leftmost free span that will hold it.
+// This is the strategy from Part 2.
+type FirstFit struct{}
+
+func (FirstFit) Name() string {
+	return "first-fit"
+}
+
+func (FirstFit) Compact(ranges []*Range) ([]*Range, Stats) {
+	out := defragWith(ranges, (*freeSpans).take)
+	return out, measure(ranges, out)
+}
+
+// BestFit moves each whole file to the smallest free span that will hold it,
+// preferring the leftmost span of that size.
+type BestFit struct{}
+
+func (BestFit) Name() string {
+	return "best-fit"
+}
+
+func (BestFit) Compact(ranges []*Range) ([]*Range, Stats) {
+	out := defragWith(ranges, func(s *freeSpans, size, limit int) (int, bool) {
+		for n := size; n <= maxSpan; n++ {
+			if len(s[n]) > 0 && s[n][0] < limit {
+				return s.takeFrom(n, size), true
+			}
+		}
+		return 0, false
+	})
+	return out, measure(ranges, out)
+}
+
+// WorstFit moves each whole file to the largest free span that will hold it,
+// preferring the leftmost span of that size.
+type WorstFit struct{}
+
+func (WorstFit) Name() string {
+	return "worst-fit"
+}
+
+func (WorstFit) Compact(ranges []*Range) ([]*Range, Stats) {
+	out := defragWith(ranges, func(s *freeSpans, size, limit int) (int, bool) {
+		for n := maxSpan; n >= size; n-- {
+			if len(s[n]) > 0 && s[n][0] < limit {
+				return s.takeFrom(n, size), true
+			}
+		}
+		return 0, false
+	})
+	return out, measure(ranges, out)
+}
+
+// LimitedFit is like FirstFit, but a file may only move left by at most K
+// blocks.
+type LimitedFit struct {
+	K int
+}
+
+func (c LimitedFit) Name() string {
+	return fmt.Sprintf("limited-%d", c.K)
+}
+
+func (c LimitedFit) Compact(ranges []*Range) ([]*Range, Stats) {
+	// The free spans, in order. Taking space from the start of a span
+	// keeps them in order, so no index is needed to find the spans in
+	// range of a file.
+	type span struct{ pos, size int }
+	var spans []span
+	for _, r := range ranges {
+		if r.Free && r.Size > 0 {
+			spans = append(spans, span{r.Pos, r.Size})
+		}
+	}
+
+	var files []*Range
+	for i := len(ranges) - 1; i >= 0; i-- {
+		r := ranges[i]
+		if r.Free {
+			continue
+		}
+		f := *r
+		lo := f.Pos - c.K
+		i := sort.Search(len(spans), func(i int) bool {
+			return spans[i].pos >= lo
+		})
+		for ; i < len(spans) && spans[i].pos < f.Pos; i++ {
+			if spans[i].size >= f.Size {
+				f.Pos = spans[i].pos
+				spans[i].pos += f.Size
+				spans[i].size -= f.Size
+				break
+			}
+		}
+		files = append(files, &f)
+	}
+	out := layout(files, diskSize(ranges))
+	return out, measure(ranges, out)
+}
+
+// MinFragmentation packs every file at the start of the disk, in the order
+// they started in, leaving no free space between them. Every file that isn't
+// already packed moves.
+type MinFragmentation struct{}
+
+func (MinFragmentation) Name() string {
+	return "min-fragmentation"
+}
+
+func (MinFragmentation) Compact(ranges []*Range) ([]*Range, Stats) {
+	var files []*Range
+	pos := 0
+	for _, r := range ranges {
+		if !r.Free {
+			f := *r
+			f.Pos = pos
+			pos += f.Size
+			files = append(files, &f)
+		}
+	}
+	out := layout(files, diskSize(ranges))
+	return out, measure(ranges, out)
+}
+
+// Compactors lists the available strategies, with LimitedFit using k.
+func Compactors(k int) []Compactor {
+	return []Compactor{
+		BlockCompactor{},
+		FirstFit{},
+		BestFit{},
+		WorstFit{},
+		LimitedFit{k},
+		MinFragmentation{},
+	}
+}
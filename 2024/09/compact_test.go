@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// checkLayout verifies that out is a valid arrangement of the files in ranges.
+func checkLayout(t *testing.T, ranges, out []*Range) {
+	t.Helper()
+	sizes := make(map[int]int)
+	for _, r := range ranges {
+		if !r.Free {
+			sizes[r.File] += r.Size
+		}
+	}
+	pos := 0
+	for _, r := range out {
+		require.Equal(t, pos, r.Pos, "ranges must be contiguous")
+		if !r.Free {
+			sizes[r.File] -= r.Size
+		}
+		pos += r.Size
+	}
+	assert.Equal(t, diskSize(ranges), pos)
+	for id, size := range sizes {
+		assert.Zero(t, size, "file %d", id)
+	}
+}
+
+// naiveFit is a simple, slow version of the whole-file strategies. Of the free
+// spans to the left of each file that will hold it, it moves the file to the
+// one that better returns true for.
+func naiveFit(ranges []*Range, better func(a, b *Range) bool) []*Range {
+	ranges = cloneRanges(ranges)
+	var files []*Range
+	for i := len(ranges) - 1; i >= 0; i-- {
+		f := ranges[i]
+		if f.Free {
+			continue
+		}
+		var best *Range
+		for _, r := range ranges[:i] {
+			if r.Free && r.Size >= f.Size && (best == nil || better(r, best)) {
+				best = r
+			}
+		}
+		if best != nil {
+			f.Pos = best.Pos
+			best.Pos += f.Size
+			best.Size -= f.Size
+		}
+		files = append(files, f)
+	}
+	return layout(files, diskSize(ranges))
+}
+
+func TestCompactors(t *testing.T) {
+	bestFit := func(a, b *Range) bool {
+		return a.Size < b.Size || (a.Size == b.Size && a.Pos < b.Pos)
+	}
+	worstFit := func(a, b *Range) bool {
+		return a.Size > b.Size || (a.Size == b.Size && a.Pos < b.Pos)
+	}
+	for seed := range uint64(20) {
+		t.Run(fmt.Sprintf("synthetic/%d", seed), func(t *testing.T) {
+			v, err := parse(strings.NewReader(syntheticDiskMap(501, seed)))
+			require.NoError(t, err)
+			want := calcChecksum(defrag2(cloneRanges(v)))
+			for _, c := range Compactors(50) {
+				out, _ := c.Compact(v)
+				if c.Name() != "block" {
+					checkLayout(t, v, out)
+				}
+			}
+
+			out, _ := FirstFit{}.Compact(v)
+			assert.Equal(t, want, calcChecksum(out))
+			out, _ = LimitedFit{math.MaxInt / 2}.Compact(v)
+			assert.Equal(t, want, calcChecksum(out))
+			out, _ = LimitedFit{0}.Compact(v)
+			assert.Equal(t, calcChecksum(v), calcChecksum(out))
+
+			out, _ = BestFit{}.Compact(v)
+			assert.Equal(t, calcChecksum(naiveFit(v, bestFit)), calcChecksum(out))
+			out, _ = WorstFit{}.Compact(v)
+			assert.Equal(t, calcChecksum(naiveFit(v, worstFit)), calcChecksum(out))
+		})
+	}
+}
+
+func TestLimitedFit(t *testing.T) {
+	v, err := parseInput("input.txt")
+	require.NoError(t, err)
+	const k = 1000
+	out, _ := LimitedFit{k}.Compact(v)
+	start := make(map[int]int)
+	for _, r := range v {
+		if !r.Free {
+			start[r.File] = r.Pos
+		}
+	}
+	for _, r := range out {
+		if !r.Free {
+			assert.LessOrEqual(t, start[r.File]-r.Pos, k)
+			assert.GreaterOrEqual(t, start[r.File]-r.Pos, 0)
+		}
+	}
+}
+
+func TestStats(t *testing.T) {
+	for _, tc := range []struct {
+		c    Compactor
+		want Stats
+	}{
+		// 00...111...2...333.44.5555.6666.777.888899
+		// 0099811188827773336446555566..............
+		{BlockCompactor{}, Stats{7, 0, 0}},
+		// 00992111777.44.333....5555.6666.....8888..
+		{FirstFit{}, Stats{4, 5, 12.0 / 14.0}},
+		// 0011123334455556666777888899..............
+		{MinFragmentation{}, Stats{9, 0, 0}},
+	} {
+		t.Run(tc.c.Name(), func(t *testing.T) {
+			v, err := parseInput("test.txt")
+			require.NoError(t, err)
+			out, stats := tc.c.Compact(v)
+			assert.Equal(t, tc.want.Moves, stats.Moves)
+			assert.Equal(t, tc.want.FreeSpans, stats.FreeSpans)
+			assert.InDelta(t, tc.want.Fragmentation, stats.Fragmentation, 1e-9)
+			if tc.c.Name() == "min-fragmentation" {
+				assert.Equal(t, "0011123334455556666777888899..............", renderBlocks(out, 0, diskSize(out)))
+			}
+		})
+	}
+}
+
+func BenchmarkCompactors(b *testing.B) {
+	v, err := parse(strings.NewReader(syntheticDiskMap(100000, 1)))
+	require.NoError(b, err)
+	for _, c := range Compactors(1000) {
+		b.Run(c.Name(), func(b *testing.B) {
+			for range b.N {
+				c.Compact(v)
+			}
+		})
+	}
+}
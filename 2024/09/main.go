@@ -2,12 +2,16 @@ package main
 
 import (
 	"bufio"
+	"container/heap"
+	"flag"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"slices"
+	"strconv"
 	"strings"
+	"text/tabwriter"
 	"unicode"
 )
 
@@ -24,50 +28,200 @@ func parseInput(filename string) ([]*Range, error) {
 		return nil, err
 	}
 	defer file.Close()
+	return parse(file)
+}
 
+func parse(r io.Reader) ([]*Range, error) {
 	var ranges []*Range
-	pos := 0
-	fid := 0
-	free := false
-	reader := bufio.NewReader(file)
+	s := NewDiskScanner(r)
+	for s.Scan() {
+		r := s.Range()
+		ranges = append(ranges, &r)
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return ranges, nil
+}
+
+// SyntaxError reports a character that isn't allowed in a disk map.
+type SyntaxError struct {
+	Char   rune
+	Offset int // in bytes
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("invalid character %q at offset %d", e.Char, e.Offset)
+}
+
+// DiskScanner reads a disk map one digit at a time, so that very long maps
+// can be processed without holding every range in memory. Trailing
+// whitespace, such as a final newline, is ignored.
+type DiskScanner struct {
+	reader *bufio.Reader
+	offset int
+	r      Range
+	fid    int
+	free   bool
+	err    error
+}
+
+func NewDiskScanner(r io.Reader) *DiskScanner {
+	return &DiskScanner{reader: bufio.NewReader(r)}
+}
+
+// Scan advances to the next range, returning false at the end of the input
+// or if there is an error.
+func (s *DiskScanner) Scan() bool {
+	if s.err != nil {
+		return false
+	}
+	c, n, err := s.reader.ReadRune()
+	if err != nil {
+		if err != io.EOF {
+			s.err = err
+		}
+		return false
+	}
+	if unicode.IsSpace(c) {
+		return s.skipSpace(c, n)
+	}
+	if c < '0' || c > '9' {
+		s.err = &SyntaxError{c, s.offset}
+		return false
+	}
+	s.offset += n
+
+	d := int(c - '0')
+	s.r = Range{s.free, 0, s.r.Pos + s.r.Size, d}
+	if !s.free {
+		s.r.File = s.fid
+		s.fid++
+	}
+	s.free = !s.free
+	return true
+}
+
+// skipSpace consumes whitespace, which is only allowed at the end of the
+// input. If more digits follow, the first whitespace character is reported
+// as the error. Anything else is reported as is.
+func (s *DiskScanner) skipSpace(c rune, n int) bool {
+	start := s.offset
+	s.offset += n
 	for {
-		c, _, err := reader.ReadRune()
+		c2, n, err := s.reader.ReadRune()
 		if err == io.EOF {
-			break
+			return false
 		}
 		if err != nil {
-			return nil, err
+			s.err = err
+			return false
 		}
-		if !unicode.IsDigit(c) {
-			return nil, fmt.Errorf("invalid character: %q", c)
+		if unicode.IsSpace(c2) {
+			s.offset += n
+			continue
 		}
-		d := int(c - '0')
-		r := &Range{free, 0, pos, d}
-		if !free {
-			r.File = fid
-			fid++
+		if c2 >= '0' && c2 <= '9' {
+			s.err = &SyntaxError{c, start}
+		} else {
+			s.err = &SyntaxError{c2, s.offset}
 		}
-		pos += d
-		free = !free
-		ranges = append(ranges, r)
+		return false
 	}
-	return ranges, nil
 }
 
-func printRanges(ranges []*Range) {
+// Range returns the most recent range read by Scan.
+func (s *DiskScanner) Range() Range {
+	return s.r
+}
+
+// Err returns the first error encountered, if any.
+func (s *DiskScanner) Err() error {
+	return s.err
+}
+
+// diskSize returns the number of blocks covered by ranges.
+func diskSize(ranges []*Range) int {
+	if len(ranges) == 0 {
+		return 0
+	}
+	last := ranges[len(ranges)-1]
+	return last.Pos + last.Size
+}
+
+// renderBlocks draws the blocks of the disk in [start, end). If every file id
+// is a single digit it uses the layout from the puzzle, one character per
+// block. Otherwise each block is drawn as a column wide enough for the
+// largest id, with columns separated by spaces.
+func renderBlocks(ranges []*Range, start, end int) string {
+	maxID := 0
+	for _, r := range ranges {
+		if !r.Free && r.File > maxID {
+			maxID = r.File
+		}
+	}
+	width := len(strconv.Itoa(maxID))
+	free := strings.Repeat(".", width)
+
 	var s strings.Builder
 	for _, r := range ranges {
-		var c rune
-		if r.Free {
-			c = '.'
+		lo := max(r.Pos, start)
+		hi := min(r.Pos+r.Size, end)
+		for pos := lo; pos < hi; pos++ {
+			if width > 1 && pos > start {
+				s.WriteByte(' ')
+			}
+			if r.Free {
+				s.WriteString(free)
+			} else {
+				fmt.Fprintf(&s, "%*d", width, r.File)
+			}
+		}
+	}
+	return s.String()
+}
+
+// renderWindow draws the blocks within radius of pos.
+func renderWindow(ranges []*Range, pos, radius int) string {
+	start := max(pos-radius, 0)
+	end := min(pos+radius+1, diskSize(ranges))
+	return renderBlocks(ranges, start, end)
+}
+
+// summarize returns a run-length summary of the disk, e.g. "0x2 .x3 1x3".
+// Adjacent ranges holding the same file, or free space, are merged.
+func summarize(ranges []*Range) string {
+	var runs []string
+	var prev *Range
+	var size int
+	flush := func() {
+		if prev == nil || size == 0 {
+			return
+		}
+		if prev.Free {
+			runs = append(runs, fmt.Sprintf(".x%d", size))
 		} else {
-			c = '0' + rune(r.File)
+			runs = append(runs, fmt.Sprintf("%dx%d", prev.File, size))
+		}
+	}
+	for _, r := range ranges {
+		if r.Size == 0 {
+			continue
 		}
-		for range r.Size {
-			s.WriteRune(c)
+		if prev != nil && prev.Free == r.Free && (r.Free || prev.File == r.File) {
+			size += r.Size
+			continue
 		}
+		flush()
+		prev = r
+		size = r.Size
 	}
-	fmt.Println(s.String())
+	flush()
+	return strings.Join(runs, " ")
+}
+
+func printRanges(ranges []*Range) {
+	fmt.Println(renderBlocks(ranges, 0, diskSize(ranges)))
 }
 
 func defrag1(ranges []*Range) []*Range {
@@ -198,17 +352,170 @@ func defrag2(ranges []*Range) []*Range {
 	return ranges
 }
 
+// posHeap is a min-heap of disk positions.
+type posHeap []int
+
+func (h posHeap) Len() int           { return len(h) }
+func (h posHeap) Less(i, j int) bool { return h[i] < h[j] }
+func (h posHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *posHeap) Push(x any)        { *h = append(*h, x.(int)) }
+func (h *posHeap) Pop() any {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
+}
+
+// maxSpan is the largest free span a disk map can describe with one digit.
+const maxSpan = 9
+
+// freeSpans indexes the free space on a disk by size. Each heap holds the
+// start positions of the free spans of that size, so the leftmost span that
+// can hold a file is the smallest head of the heaps at least as big as it.
+type freeSpans [maxSpan + 1]posHeap
+
+func newFreeSpans(ranges []*Range) *freeSpans {
+	var spans freeSpans
+	for _, r := range ranges {
+		if r.Free && r.Size > 0 {
+			spans[r.Size] = append(spans[r.Size], r.Pos)
+		}
+	}
+	for i := range spans {
+		heap.Init(&spans[i])
+	}
+	return &spans
+}
+
+// take removes the leftmost span before limit that can hold size blocks, and
+// returns its position. Any space left over is returned to the index.
+func (s *freeSpans) take(size, limit int) (int, bool) {
+	best := -1
+	for n := size; n <= maxSpan; n++ {
+		h := s[n]
+		if len(h) > 0 && h[0] < limit && (best == -1 || h[0] < s[best][0]) {
+			best = n
+		}
+	}
+	if best == -1 {
+		return 0, false
+	}
+	return s.takeFrom(best, size), true
+}
+
+// takeFrom removes the leftmost span of length n and returns its position.
+// The size blocks at the start of the span are used, and any space left over
+// is returned to the index.
+func (s *freeSpans) takeFrom(n, size int) int {
+	pos := heap.Pop(&s[n]).(int)
+	if rest := n - size; rest > 0 {
+		heap.Push(&s[rest], pos+size)
+	}
+	return pos
+}
+
+// defragHeap moves whole files like defrag2, but finds free space using a
+// heap per span size instead of scanning from the start of the disk. Each
+// move is O(log n). Unlike defrag2 it returns new ranges rather than updating
+// the input.
+func defragHeap(ranges []*Range) []*Range {
+	return defragWith(ranges, (*freeSpans).take)
+}
+
+// defragWith moves each file once, starting with the last, to the free span
+// chosen by take. take is given the size of the file and its position, and
+// must only choose spans to the left of it.
+func defragWith(ranges []*Range, take func(s *freeSpans, size, limit int) (int, bool)) []*Range {
+	spans := newFreeSpans(ranges)
+	var files []*Range
+	for i := len(ranges) - 1; i >= 0; i-- {
+		r := ranges[i]
+		if r.Free {
+			continue
+		}
+		f := *r
+		if pos, ok := take(spans, f.Size, f.Pos); ok {
+			f.Pos = pos
+		}
+		files = append(files, &f)
+	}
+	return layout(files, diskSize(ranges))
+}
+
+// layout sorts files by position and fills the gaps between them, and up to
+// the end of the disk, with free ranges.
+func layout(files []*Range, size int) []*Range {
+	slices.SortFunc(files, func(a, b *Range) int {
+		return a.Pos - b.Pos
+	})
+	out := make([]*Range, 0, 2*len(files))
+	pos := 0
+	for _, f := range files {
+		if f.Pos > pos {
+			out = append(out, &Range{Free: true, File: -1, Pos: pos, Size: f.Pos - pos})
+		}
+		out = append(out, f)
+		pos = f.Pos + f.Size
+	}
+	if size > pos {
+		out = append(out, &Range{Free: true, File: -1, Pos: pos, Size: size - pos})
+	}
+	return out
+}
+
 func Part2(ranges []*Range) int {
 	ranges = defrag2(ranges)
 	return calcChecksum(ranges)
 }
 
 func main() {
-	ranges, err := parseInput(os.Args[1])
-	if err != nil {
-		log.Fatal(err)
+	show := flag.Bool("show", false, "print the disk before and after compacting it")
+	window := flag.Int("window", -1, "only show the blocks around this position")
+	radius := flag.Int("radius", 20, "number of blocks to show either side of -window")
+	summary := flag.Bool("summary", false, "print a run-length summary of the disk")
+	compare := flag.Bool("compare", false, "compare the available compaction strategies")
+	k := flag.Int("k", 1000, "the furthest a file may move with the limited strategy")
+	flag.Parse()
+
+	if *compare {
+		ranges, err := parseInput(flag.Arg(0))
+		if err != nil {
+			log.Fatal(err)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintln(w, "strategy\tchecksum\tmoves\tfree spans\tfragmentation\t")
+		for _, c := range Compactors(*k) {
+			out, stats := c.Compact(ranges)
+			fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%.3f\t\n", c.Name(), calcChecksum(out), stats.Moves, stats.FreeSpans, stats.Fragmentation)
+		}
+		w.Flush()
+		return
+	}
+
+	dump := func(ranges []*Range) {
+		if *summary {
+			fmt.Println(summarize(ranges))
+		}
+		if !*show {
+			return
+		}
+		if *window >= 0 {
+			fmt.Println(renderWindow(ranges, *window, *radius))
+		} else {
+			printRanges(ranges)
+		}
+	}
+
+	for i, defrag := range []func([]*Range) []*Range{defrag1, defrag2} {
+		// Compacting updates the ranges in place, so start over each time.
+		ranges, err := parseInput(flag.Arg(0))
+		if err != nil {
+			log.Fatal(err)
+		}
+		dump(ranges)
+		ranges = defrag(ranges)
+		dump(ranges)
+		fmt.Printf("Part %d: %d\n", i+1, calcChecksum(ranges))
 	}
-	printRanges(ranges)
-	ranges = defrag2(ranges)
-	printRanges(ranges)
 }
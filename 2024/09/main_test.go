@@ -1,6 +1,10 @@
 package main
 
 import (
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -26,3 +30,131 @@ func TestPart2(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, 6227018762750, Part2(v))
 }
+
+// syntheticDiskMap returns a random disk map with n digits.
+func syntheticDiskMap(n int, seed uint64) string {
+	rng := rand.New(rand.NewPCG(seed, seed))
+	var s strings.Builder
+	for i := range n {
+		if i%2 == 0 {
+			// Files are never empty
+			s.WriteByte(byte('1' + rng.IntN(9)))
+		} else {
+			s.WriteByte(byte('0' + rng.IntN(10)))
+		}
+	}
+	return s.String()
+}
+
+func TestDefragHeap(t *testing.T) {
+	for _, filename := range []string{"test.txt", "input.txt"} {
+		t.Run(filename, func(t *testing.T) {
+			v, err := parseInput(filename)
+			require.NoError(t, err)
+			got := calcChecksum(defragHeap(v))
+			assert.Equal(t, calcChecksum(defrag2(v)), got)
+		})
+	}
+	for seed := range uint64(20) {
+		t.Run(fmt.Sprintf("synthetic/%d", seed), func(t *testing.T) {
+			disk := syntheticDiskMap(1001, seed)
+			v, err := parse(strings.NewReader(disk))
+			require.NoError(t, err)
+			got := defragHeap(v)
+			want := defrag2(v)
+			assert.Equal(t, calcChecksum(want), calcChecksum(got))
+
+			// The ranges should cover the whole disk without gaps.
+			var pos int
+			for _, r := range got {
+				assert.Equal(t, pos, r.Pos)
+				pos += r.Size
+			}
+			last := want[len(want)-1]
+			assert.Equal(t, last.Pos+last.Size, pos)
+		})
+	}
+}
+
+func BenchmarkDefragHeap(b *testing.B) {
+	v, err := parse(strings.NewReader(syntheticDiskMap(100000, 1)))
+	require.NoError(b, err)
+	b.ResetTimer()
+	for range b.N {
+		defragHeap(v)
+	}
+}
+
+func BenchmarkDefrag2(b *testing.B) {
+	disk := syntheticDiskMap(100000, 1)
+	for range b.N {
+		b.StopTimer()
+		v, err := parse(strings.NewReader(disk))
+		require.NoError(b, err)
+		b.StartTimer()
+		defrag2(v)
+	}
+}
+
+func TestRender(t *testing.T) {
+	v, err := parseInput("test.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "00...111...2...333.44.5555.6666.777.888899", renderBlocks(v, 0, diskSize(v)))
+	assert.Equal(t, "111...2", renderWindow(v, 8, 3))
+	assert.Equal(t, "00.", renderWindow(v, 0, 2))
+	assert.Equal(t, "0x2 .x3 1x3 .x3 2x1 .x3 3x3 .x1 4x2 .x1 5x4 .x1 6x4 .x1 7x3 .x1 8x4 9x2", summarize(v))
+
+	// Ids above 9 are drawn in columns.
+	v, err = parse(strings.NewReader("121212121212121212121"))
+	require.NoError(t, err)
+	assert.Equal(t, " 0 .. ..  1 ..", renderBlocks(v, 0, 5))
+	assert.Equal(t, ".. ..  9 .. .. 10", renderWindow(v, 30, 5))
+	assert.Equal(t, "0x1 .x2 1x1 .x2 2x1 .x2 3x1 .x2 4x1 .x2 5x1 .x2 6x1 .x2 7x1 .x2 8x1 .x2 9x1 .x2 10x1", summarize(v))
+}
+
+func TestParse(t *testing.T) {
+	for _, tc := range []struct {
+		in  string
+		err error
+	}{
+		{"2333133121414131402", nil},
+		{"2333133121414131402\n", nil},
+		{"2333133121414131402 \r\n\t\n", nil},
+		{"", nil},
+		{"23331x3121414131402", &SyntaxError{'x', 5}},
+		{"233313312141413140\n2", &SyntaxError{'\n', 18}},
+		{"2333133121414131402\n\nfoo", &SyntaxError{'f', 21}},
+		{"23٣", &SyntaxError{'٣', 2}},
+		{"2٣x", &SyntaxError{'٣', 1}},
+	} {
+		t.Run(strconv.Quote(tc.in), func(t *testing.T) {
+			v, err := parse(strings.NewReader(tc.in))
+			if tc.err != nil {
+				assert.Equal(t, tc.err, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, len(strings.TrimSpace(tc.in)), len(v))
+		})
+	}
+}
+
+func TestDiskScanner(t *testing.T) {
+	// Check the ranges without building the whole list.
+	s := NewDiskScanner(strings.NewReader(syntheticDiskMap(100001, 1) + "\n"))
+	var n, pos, files int
+	for s.Scan() {
+		r := s.Range()
+		assert.Equal(t, pos, r.Pos)
+		assert.Equal(t, n%2 == 1, r.Free)
+		if !r.Free {
+			assert.Equal(t, files, r.File)
+			files++
+		}
+		pos += r.Size
+		n++
+	}
+	require.NoError(t, s.Err())
+	assert.Equal(t, 100001, n)
+	assert.Equal(t, 50001, files)
+}
@@ -2,29 +2,94 @@ package main
 
 import (
 	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"iter"
 	"log"
 	"os"
+	"slices"
 	"strings"
-	"unicode"
 )
 
+type Point struct {
+	Y int `json:"y"`
+	X int `json:"x"`
+}
+
+func (p Point) String() string {
+	return fmt.Sprintf("(%d, %d)", p.Y, p.X)
+}
+
 type Node struct {
 	Value int
+	Pos   Point
+	End   bool // true if trails end here
 	Edges []*Node
 }
 
+// Rules describe what makes a valid trail.
+type Rules struct {
+	// Trails start at the Start height and end at the End height.
+	Start, End int
+	// Each step must change the height by between MinStep and MaxStep,
+	// inclusive. A step never stays at the same height.
+	MinStep, MaxStep int
+	// Diagonal allows steps to the 4 diagonal neighbours as well.
+	Diagonal bool
+}
+
+// PuzzleRules are the rules from the puzzle: trails run from 0 to 9, going
+// up by exactly 1 with every step.
+var PuzzleRules = Rules{Start: 0, End: 9, MinStep: 1, MaxStep: 1}
+
+func (r Rules) allows(from, to *Node) bool {
+	step := to.Value - from.Value
+	return step != 0 && step >= r.MinStep && step <= r.MaxStep
+}
+
+// Neighbours in the order edges are added: up, down, left, right, then the
+// diagonals.
+var directions = []Point{
+	{-1, 0}, {1, 0}, {0, -1}, {0, 1},
+	{-1, -1}, {-1, 1}, {1, -1}, {1, 1},
+}
+
+// height returns the height of a map square. Heights above 9 are written as
+// letters, so 'a' is 10 and 'z' is 35.
+func height(c rune) (int, bool) {
+	switch {
+	case c >= '0' && c <= '9':
+		return int(c - '0'), true
+	case c >= 'a' && c <= 'z':
+		return int(c-'a') + 10, true
+	}
+	return 0, false
+}
+
 func parseInput(filename string) ([]*Node, error) {
+	return parseInputRules(filename, PuzzleRules)
+}
+
+func parseInputRules(filename string, rules Rules) ([]*Node, error) {
 	file, err := os.Open(filename)
 	if err != nil {
 		return nil, err
 	}
 	defer file.Close()
+	return parse(file, rules)
+}
 
+// parse reads a map and returns the trailheads, linked to the rest of the
+// trail graph according to rules.
+func parse(r io.Reader, rules Rules) ([]*Node, error) {
 	var trailheads []*Node
 	var nodes [][]*Node
 
 	// Build nodes
-	scanner := bufio.NewScanner(file)
+	scanner := bufio.NewScanner(r)
 	for scanner.Scan() {
 		line := scanner.Text()
 		line = strings.TrimSpace(line)
@@ -33,51 +98,48 @@ func parseInput(filename string) ([]*Node, error) {
 		}
 		ns := make([]*Node, len(line))
 		for i, c := range line {
-			if !unicode.IsDigit(c) {
+			d, ok := height(c)
+			if !ok {
 				continue
 			}
-			d := int(c - '0')
-			ns[i] = &Node{Value: d}
+			ns[i] = &Node{Value: d, Pos: Point{len(nodes), i}, End: d == rules.End}
 		}
 		nodes = append(nodes, ns)
 	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
 
 	// Build edges
+	dirs := directions[:4]
+	if rules.Diagonal {
+		dirs = directions
+	}
 	for i, ns := range nodes {
 		for j, n := range ns {
 			if n == nil {
 				continue
 			}
-			if n.Value == 0 {
+			if n.Value == rules.Start {
 				trailheads = append(trailheads, n)
 			}
-			if i > 0 {
-				n2 := nodes[i-1][j]
-				if n2 != nil && n2.Value == n.Value+1 {
-					n.Edges = append(n.Edges, n2)
-				}
+			if n.End {
+				// Trails stop as soon as they reach the end.
+				continue
 			}
-			if i < len(nodes)-1 {
-				n2 := nodes[i+1][j]
-				if n2 != nil && n2.Value == n.Value+1 {
-					n.Edges = append(n.Edges, n2)
+			for _, d := range dirs {
+				y, x := i+d.Y, j+d.X
+				if y < 0 || y >= len(nodes) || x < 0 || x >= len(nodes[y]) {
+					continue
 				}
-			}
-			if j > 0 {
-				n2 := ns[j-1]
-				if n2 != nil && n2.Value == n.Value+1 {
+				n2 := nodes[y][x]
+				if n2 != nil && rules.allows(n, n2) {
 					n.Edges = append(n.Edges, n2)
 				}
 			}
-			if j < len(ns)-1 {
-				n2 := ns[j+1]
-				if n2 != nil && n2.Value == n.Value+1 {
-					n.Edges = append(n.Edges, ns[j+1])
-				}
-			}
 		}
 	}
-	return trailheads, scanner.Err()
+	return trailheads, nil
 }
 
 func push(stack []*Node, n *Node) []*Node {
@@ -101,7 +163,7 @@ func score(n *Node) int {
 	for n != nil {
 		//fmt.Printf("visit: %v (%d) -> %v\n", n, n.Value, n.Edges)
 		visits[n] = true
-		if n.Value == 9 {
+		if n.End {
 			nines[n] = true
 		}
 		for _, e := range n.Edges {
@@ -126,7 +188,7 @@ func rating(n *Node, ratings map[*Node]int) int {
 	if r, ok := ratings[n]; ok {
 		return r
 	}
-	if n.Value == 9 {
+	if n.End {
 		ratings[n] = 1
 		return 1
 	}
@@ -138,18 +200,265 @@ func rating(n *Node, ratings map[*Node]int) int {
 	return r
 }
 
-func Part2(trailheads []*Node) int {
-	ratings := make(map[*Node]int)
+// hasCycle returns true if a trail can return to a node it has already
+// visited. This can only happen if the rules allow steps both up and down.
+func hasCycle(trailheads []*Node) bool {
+	const (
+		unvisited = iota
+		active
+		done
+	)
+	state := make(map[*Node]int)
+	var visit func(n *Node) bool
+	visit = func(n *Node) bool {
+		switch state[n] {
+		case active:
+			return true
+		case done:
+			return false
+		}
+		state[n] = active
+		for _, e := range n.Edges {
+			if visit(e) {
+				return true
+			}
+		}
+		state[n] = done
+		return false
+	}
+	for _, n := range trailheads {
+		if visit(n) {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrTooManySteps is returned when following trails one by one takes more
+// steps than allowed. The number of trails can grow exponentially with their
+// length when the rules allow steps both up and down, as with ±1 steps on
+// the puzzle input.
+var ErrTooManySteps = errors.New("too many steps following trails")
+
+// MaxTrailSteps is the most steps that Part2 takes following trails one by
+// one, across all trailheads, before it gives up with ErrTooManySteps.
+const MaxTrailSteps = 10_000_000
+
+// ratings returns a function that calculates the rating of a trailhead. If
+// the trail graph has cycles, trails that visit the same square twice are
+// not counted, and the trails have to be counted one by one. Rather than
+// take forever doing that, the function returns ErrTooManySteps once it has
+// taken maxSteps steps, counting every call.
+func ratings(trailheads []*Node, maxSteps int) func(*Node) (int, error) {
+	if hasCycle(trailheads) {
+		budget := maxSteps
+		return func(n *Node) (int, error) {
+			var count int
+			err := walkTrails(n, &budget, func([]Point) bool {
+				count++
+				return true
+			})
+			return count, err
+		}
+	}
+	memo := make(map[*Node]int)
+	return func(n *Node) (int, error) {
+		return rating(n, memo), nil
+	}
+}
+
+func Part2(trailheads []*Node) (int, error) {
+	rating := ratings(trailheads, MaxTrailSteps)
 	var sum int
 	for _, n := range trailheads {
-		sum += rating(n, ratings)
+		r, err := rating(n)
+		if err != nil {
+			return 0, fmt.Errorf("rating trailhead %s: %w", n.Pos, err)
+		}
+		sum += r
+	}
+	return sum, nil
+}
+
+// trails returns every distinct trail from n to the end height, as the list
+// of positions visited. Trails never visit the same square twice. The slice
+// is reused between iterations, so callers that keep it must make a copy.
+func trails(n *Node) iter.Seq[[]Point] {
+	return func(yield func([]Point) bool) {
+		walkTrails(n, nil, yield)
 	}
-	return sum
+}
+
+// walkTrails calls yield with each trail from n, like trails, until yield
+// returns false. If budget isn't nil, each step uses up one of the steps left
+// in it, and once there are none left it stops with ErrTooManySteps.
+func walkTrails(n *Node, budget *int, yield func([]Point) bool) error {
+	var path []Point
+	onPath := make(map[*Node]bool)
+	var err error
+	var walk func(n *Node) bool
+	walk = func(n *Node) bool {
+		if onPath[n] {
+			return true
+		}
+		if budget != nil {
+			if *budget <= 0 {
+				err = ErrTooManySteps
+				return false
+			}
+			*budget--
+		}
+		path = append(path, n.Pos)
+		onPath[n] = true
+		defer func() {
+			path = path[:len(path)-1]
+			onPath[n] = false
+		}()
+		if n.End {
+			return yield(path)
+		}
+		for _, e := range n.Edges {
+			if !walk(e) {
+				return false
+			}
+		}
+		return true
+	}
+	walk(n)
+	return err
+}
+
+// reachable returns every node that can be reached from the trailheads,
+// in the order they were first visited.
+func reachable(trailheads []*Node) []*Node {
+	var out []*Node
+	seen := make(map[*Node]bool)
+	var visit func(n *Node)
+	visit = func(n *Node) {
+		if seen[n] {
+			return
+		}
+		seen[n] = true
+		out = append(out, n)
+		for _, e := range n.Edges {
+			visit(e)
+		}
+	}
+	for _, n := range trailheads {
+		visit(n)
+	}
+	return out
+}
+
+func nodeID(n *Node) string {
+	return fmt.Sprintf("n%d_%d", n.Pos.Y, n.Pos.X)
+}
+
+// writeDOT writes the trail graph in Graphviz format. Trailheads are drawn
+// as boxes and the ends of trails as double circles.
+func writeDOT(w io.Writer, trailheads []*Node) error {
+	var s strings.Builder
+	s.WriteString("digraph trails {\n")
+	for _, n := range reachable(trailheads) {
+		shape := "circle"
+		if slices.Contains(trailheads, n) {
+			shape = "box"
+		} else if n.End {
+			shape = "doublecircle"
+		}
+		fmt.Fprintf(&s, "  %s [label=\"%d\\n%s\" shape=%s];\n", nodeID(n), n.Value, n.Pos, shape)
+		for _, e := range n.Edges {
+			fmt.Fprintf(&s, "  %s -> %s;\n", nodeID(n), nodeID(e))
+		}
+	}
+	s.WriteString("}\n")
+	_, err := io.WriteString(w, s.String())
+	return err
+}
+
+type jsonNode struct {
+	ID    string   `json:"id"`
+	Pos   Point    `json:"pos"`
+	Value int      `json:"value"`
+	Edges []string `json:"edges"`
+}
+
+type jsonGraph struct {
+	Trailheads []string   `json:"trailheads"`
+	Nodes      []jsonNode `json:"nodes"`
+}
+
+// writeJSON writes the trail graph as JSON, with nodes referring to each
+// other by id.
+func writeJSON(w io.Writer, trailheads []*Node) error {
+	var g jsonGraph
+	for _, n := range trailheads {
+		g.Trailheads = append(g.Trailheads, nodeID(n))
+	}
+	for _, n := range reachable(trailheads) {
+		jn := jsonNode{ID: nodeID(n), Pos: n.Pos, Value: n.Value, Edges: []string{}}
+		for _, e := range n.Edges {
+			jn.Edges = append(jn.Edges, nodeID(e))
+		}
+		g.Nodes = append(g.Nodes, jn)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(g)
 }
 
 func main() {
-	_, err := parseInput(os.Args[1])
+	dot := flag.Bool("dot", false, "print the trail graph in Graphviz format")
+	asJSON := flag.Bool("json", false, "print the trail graph as JSON")
+	showTrails := flag.Bool("trails", false, "print every trail from each trailhead")
+	report := flag.Bool("report", false, "print the score and rating of each trailhead")
+	rules := PuzzleRules
+	flag.IntVar(&rules.Start, "start", rules.Start, "the height trails start at")
+	flag.IntVar(&rules.End, "end", rules.End, "the height trails end at")
+	flag.IntVar(&rules.MinStep, "min-step", rules.MinStep, "the smallest change in height allowed in one step")
+	flag.IntVar(&rules.MaxStep, "max-step", rules.MaxStep, "the largest change in height allowed in one step")
+	flag.BoolVar(&rules.Diagonal, "diagonal", rules.Diagonal, "allow diagonal steps")
+	flag.Parse()
+
+	trailheads, err := parseInputRules(flag.Arg(0), rules)
+	if err != nil {
+		log.Fatal(err)
+	}
+	switch {
+	case *dot:
+		err = writeDOT(os.Stdout, trailheads)
+	case *asJSON:
+		err = writeJSON(os.Stdout, trailheads)
+	case *showTrails:
+		budget := MaxTrailSteps
+		for _, n := range trailheads {
+			fmt.Printf("Trailhead %s:\n", n.Pos)
+			err = walkTrails(n, &budget, func(path []Point) bool {
+				fmt.Printf("  %v\n", path)
+				return true
+			})
+			if err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *report {
+		rating := ratings(trailheads, MaxTrailSteps)
+		for _, n := range trailheads {
+			r, err := rating(n)
+			if err != nil {
+				log.Fatalf("%s: %v", n.Pos, err)
+			}
+			fmt.Printf("%s: score %d, rating %d\n", n.Pos, score(n), r)
+		}
+	}
+	fmt.Printf("Part 1: %d\n", Part1(trailheads))
+	part2, err := Part2(trailheads)
 	if err != nil {
 		log.Fatal(err)
 	}
+	fmt.Printf("Part 2: %d\n", part2)
 }
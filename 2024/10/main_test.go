@@ -1,6 +1,11 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -38,7 +43,149 @@ func TestPart2(t *testing.T) {
 		t.Run(tc.filename, func(t *testing.T) {
 			v, err := parseInput(tc.filename)
 			require.NoError(t, err)
-			assert.Equal(t, tc.expected, Part2(v))
+			n, err := Part2(v)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, n)
+		})
+	}
+}
+
+func TestTrails(t *testing.T) {
+	trailheads, err := parseInput("test1b.txt")
+	require.NoError(t, err)
+	require.Len(t, trailheads, 1)
+	var got [][]Point
+	for path := range trails(trailheads[0]) {
+		got = append(got, slices.Clone(path))
+	}
+	assert.Equal(t, [][]Point{
+		{{0, 3}, {1, 3}, {2, 3}, {3, 3}, {3, 2}, {3, 1}, {3, 0}, {4, 0}, {5, 0}, {6, 0}},
+		{{0, 3}, {1, 3}, {2, 3}, {3, 3}, {3, 4}, {3, 5}, {3, 6}, {4, 6}, {5, 6}, {6, 6}},
+	}, got)
+
+	for _, filename := range []string{"test1a.txt", "test1b.txt", "test1c.txt", "test1d.txt", "test1e.txt"} {
+		t.Run(filename, func(t *testing.T) {
+			trailheads, err := parseInput(filename)
+			require.NoError(t, err)
+			ratings := make(map[*Node]int)
+			for _, n := range trailheads {
+				seen := make(map[string]bool)
+				for path := range trails(n) {
+					require.Len(t, path, 10)
+					for i := 1; i < len(path); i++ {
+						dy := path[i].Y - path[i-1].Y
+						dx := path[i].X - path[i-1].X
+						require.Equal(t, 1, dx*dx+dy*dy, "%v", path)
+					}
+					key := fmt.Sprint(path)
+					require.False(t, seen[key], key)
+					seen[key] = true
+				}
+				assert.Equal(t, rating(n, ratings), len(seen))
+			}
+		})
+	}
+}
+
+func TestExport(t *testing.T) {
+	trailheads, err := parseInput("test1a.txt")
+	require.NoError(t, err)
+
+	var dot bytes.Buffer
+	require.NoError(t, writeDOT(&dot, trailheads))
+	assert.True(t, strings.HasPrefix(dot.String(), "digraph trails {\n"))
+	assert.Contains(t, dot.String(), `n0_0 [label="0\n(0, 0)" shape=box];`)
+	assert.Contains(t, dot.String(), `n3_0 [label="9\n(3, 0)" shape=doublecircle];`)
+	assert.Contains(t, dot.String(), "n0_0 -> n1_0;")
+	assert.Equal(t, 21, strings.Count(dot.String(), "->"))
+
+	var buf bytes.Buffer
+	require.NoError(t, writeJSON(&buf, trailheads))
+	var g jsonGraph
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &g))
+	assert.Equal(t, []string{"n0_0"}, g.Trailheads)
+	assert.Len(t, g.Nodes, 16)
+	assert.Equal(t, jsonNode{"n0_0", Point{0, 0}, 0, []string{"n1_0", "n0_1"}}, g.Nodes[0])
+}
+
+func TestRules(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		in     string
+		rules  Rules
+		score  int
+		rating int
+	}{
+		{"puzzle", "0..\n.1.\n..2", Rules{0, 2, 1, 1, false}, 0, 0},
+		{"diagonal", "0..\n.1.\n..2", Rules{0, 2, 1, 1, true}, 1, 1},
+		{"big steps", "024\n...", Rules{0, 4, 1, 2, false}, 1, 1},
+		{"big steps not allowed", "024\n...", PuzzleRules, 0, 0},
+		{"up and down", "010\n121", Rules{0, 2, -1, 1, false}, 2, 6},
+		{"downhill", "987\n.56", Rules{9, 5, -1, -1, false}, 1, 1},
+		{"letters", "0123456789abc", Rules{0, 12, 1, 1, false}, 1, 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			trailheads, err := parse(strings.NewReader(tc.in), tc.rules)
+			require.NoError(t, err)
+			assert.Equal(t, tc.score, Part1(trailheads))
+			rating, err := Part2(trailheads)
+			require.NoError(t, err)
+			assert.Equal(t, tc.rating, rating)
 		})
 	}
 }
+
+func TestPositions(t *testing.T) {
+	trailheads, err := parseInput("test1e.txt")
+	require.NoError(t, err)
+	require.Len(t, trailheads, 9)
+	// From the puzzle description, in reading order.
+	want := map[Point][2]int{
+		{0, 2}: {5, 20},
+		{0, 4}: {6, 24},
+		{2, 4}: {5, 10},
+		{4, 6}: {3, 4},
+		{5, 2}: {1, 1},
+		{5, 5}: {3, 4},
+		{6, 0}: {5, 5},
+		{6, 6}: {3, 8},
+		{7, 1}: {5, 5},
+	}
+	rating := ratings(trailheads, MaxTrailSteps)
+	for _, n := range trailheads {
+		r, err := rating(n)
+		require.NoError(t, err)
+		assert.Equal(t, want[n.Pos], [2]int{score(n), r}, n.Pos.String())
+	}
+}
+
+func TestTooManySteps(t *testing.T) {
+	// Going up and down, there are too many trails to count one by one.
+	rules := Rules{Start: 0, End: 9, MinStep: -1, MaxStep: 1}
+	trailheads, err := parseInputRules("input.txt", rules)
+	require.NoError(t, err)
+	_, err = Part2(trailheads)
+	assert.ErrorIs(t, err, ErrTooManySteps)
+
+	// The limit covers every trailhead, not each one.
+	trailheads, err = parse(strings.NewReader("010\n121"), Rules{0, 2, -1, 1, false})
+	require.NoError(t, err)
+	require.Len(t, trailheads, 2)
+	rating := ratings(trailheads, 10)
+	var errs int
+	for _, n := range trailheads {
+		if _, err := rating(n); err != nil {
+			assert.ErrorIs(t, err, ErrTooManySteps)
+			errs++
+		}
+	}
+	assert.Positive(t, errs)
+	rating = ratings(trailheads, 1000)
+	var sum int
+	for _, n := range trailheads {
+		r, err := rating(n)
+		require.NoError(t, err)
+		sum += r
+	}
+	assert.Equal(t, 6, sum)
+}
@@ -9,3 +9,7 @@ require (
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
+
+require github.com/TonyRippy/advent-of-code/2024/checked v0.0.0
+
+replace github.com/TonyRippy/advent-of-code/2024/checked => ../checked
@@ -1,14 +1,18 @@
 package main
 
 import (
+	"container/list"
+	"context"
+	"flag"
 	"fmt"
 	"io"
 	"log"
-	"math"
 	"os"
 	"strconv"
 	"strings"
 	"sync"
+
+	"github.com/TonyRippy/advent-of-code/2024/checked"
 )
 
 func parseInput(filename string) (list []int, err error) {
@@ -41,83 +45,402 @@ func pow10(exp int) int {
 }
 
 type cacheKey struct {
-	stone, blinks int
+	stone  Stone
+	blinks int
 }
 
-var cache sync.Map
+// CacheStats reports how well a Cache is working.
+type CacheStats struct {
+	Hits, Misses, Evictions int
+	Size                    int
+}
 
-func blink(stone int, blinks int) int {
-	if blinks == 0 {
-		return 1
+func (s CacheStats) String() string {
+	return fmt.Sprintf("%d hits, %d misses, %d evictions, %d entries", s.Hits, s.Misses, s.Evictions, s.Size)
+}
+
+type cacheEntry struct {
+	key   cacheKey
+	value int
+}
+
+// Cache memoises the number of stones a stone turns into after a number of
+// blinks. If it has a size limit, the least recently used entries are evicted
+// to make room for new ones. It is safe for concurrent use.
+type Cache struct {
+	mu      sync.Mutex
+	limit   int
+	entries map[cacheKey]*list.Element
+	lru     *list.List // most recently used at the front
+	stats   CacheStats
+}
+
+// NewCache returns an empty cache that holds at most limit entries, or any
+// number of entries if limit is 0.
+func NewCache(limit int) *Cache {
+	return &Cache{
+		limit:   limit,
+		entries: make(map[cacheKey]*list.Element),
+		lru:     list.New(),
 	}
-	blinks--
-	if stone == 0 {
-		return cachedBlink(1, blinks)
+}
+
+func (c *Cache) Get(key cacheKey) (int, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		c.stats.Misses++
+		return 0, false
 	}
-	digits := int(math.Floor(math.Log10(float64(stone)))) + 1
-	if digits%2 == 0 {
-		pow := pow10(digits / 2)
-		return cachedBlink(stone%pow, blinks) + cachedBlink(stone/pow, blinks)
+	c.stats.Hits++
+	c.lru.MoveToFront(e)
+	return e.Value.(*cacheEntry).value, true
+}
+
+func (c *Cache) Put(key cacheKey, value int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.put(key, value)
+}
+
+func (c *Cache) put(key cacheKey, value int) {
+	if e, ok := c.entries[key]; ok {
+		e.Value.(*cacheEntry).value = value
+		c.lru.MoveToFront(e)
+		return
+	}
+	if c.limit > 0 && c.lru.Len() >= c.limit {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+		c.stats.Evictions++
 	}
-	return cachedBlink(stone*2024, blinks)
+	c.entries[key] = c.lru.PushFront(&cacheEntry{key, value})
+}
+
+// Merge copies the given keys from entries into the cache.
+func (c *Cache) Merge(entries map[cacheKey]int, keys []cacheKey) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range keys {
+		c.put(key, entries[key])
+	}
+}
+
+func (c *Cache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.stats
+	stats.Size = c.lru.Len()
+	return stats
 }
 
-func cachedBlink(stone int, blinks int) int {
+// Evaluator counts the stones that result from blinking under a set of
+// rules, memoising the count for each stone in a cache. A cache holds counts
+// for one set of rules, so it must not be shared with another evaluator that
+// uses different rules.
+type Evaluator struct {
+	Rules Rules
+	Cache *Cache
+}
+
+func NewEvaluator(rules Rules, cache *Cache) *Evaluator {
+	return &Evaluator{rules, cache}
+}
+
+func (e *Evaluator) blink(stone Stone, blinks int) (int, error) {
+	if blinks == 0 {
+		return 1, nil
+	}
+	var sum int
+	for _, s := range e.Rules.Step(stone) {
+		n, err := e.cachedBlink(s, blinks-1)
+		if err != nil {
+			return 0, err
+		}
+		if sum, err = checked.Add(sum, n); err != nil {
+			return 0, err
+		}
+	}
+	return sum, nil
+}
+
+func (e *Evaluator) cachedBlink(stone Stone, blinks int) (int, error) {
 	key := cacheKey{stone, blinks}
-	if out, ok := cache.Load(key); ok {
-		return out.(int)
+	if out, ok := e.Cache.Get(key); ok {
+		return out, nil
 	}
-	out := blink(stone, blinks)
-	cache.Store(key, out)
-	return out
+	out, err := e.blink(stone, blinks)
+	if err != nil {
+		return 0, err
+	}
+	e.Cache.Put(key, out)
+	return out, nil
 }
 
-func Part1(stones []int, blinks int) int {
+// Count returns the number of stones after blinking. It returns
+// checked.ErrOverflow if there are too many stones to count in an int.
+func (e *Evaluator) Count(stones []Stone, blinks int) (int, error) {
 	var sum int
 	for _, stone := range stones {
-		sum += blink(stone, blinks)
+		n, err := e.blink(stone, blinks)
+		if err != nil {
+			return 0, err
+		}
+		if sum, err = checked.Add(sum, n); err != nil {
+			return 0, err
+		}
+	}
+	return sum, nil
+}
+
+// Count returns the number of stones after blinking under the puzzle rules,
+// memoising the count for each stone in cache.
+func Count(stones []int, blinks int, cache *Cache) (int, error) {
+	return NewEvaluator(PuzzleRules, cache).Count(toStones(stones), blinks)
+}
+
+// CountMapWith returns the number of stones after blinking. Rather than
+// following each stone, it tracks how many stones there are with each value,
+// and advances them all one blink at a time. It returns checked.ErrOverflow
+// if there are too many stones to count in an int.
+func CountMapWith(rules Rules, stones []Stone, blinks int) (int, error) {
+	counts := make(map[Stone]int)
+	for _, stone := range stones {
+		counts[stone]++
 	}
-	return sum
+	for range blinks {
+		next := make(map[Stone]int, len(counts))
+		for stone, n := range counts {
+			for _, s := range rules.Step(stone) {
+				var err error
+				if next[s], err = checked.Add(next[s], n); err != nil {
+					return 0, err
+				}
+			}
+		}
+		counts = next
+	}
+	var sum int
+	for _, n := range counts {
+		var err error
+		if sum, err = checked.Add(sum, n); err != nil {
+			return 0, err
+		}
+	}
+	return sum, nil
 }
 
-func Part2(stones []int) int {
-	in := make(chan int, 1000)
-	out := make(chan int, 1000)
+// CountMap is CountMapWith using the puzzle rules.
+func CountMap(stones []int, blinks int) (int, error) {
+	return CountMapWith(PuzzleRules, toStones(stones), blinks)
+}
+
+func Part1(stones []int, blinks int) (int, error) {
+	return Count(stones, blinks, NewCache(0))
+}
+
+// worker counts stones for CountContext. It keeps its own cache, so that it
+// only needs the lock on the shared cache to look up each stone it is given,
+// and to merge in the entries it has added since it last did so.
+type worker struct {
+	ctx     context.Context
+	rules   Rules
+	shared  *Cache
+	local   map[cacheKey]int
+	pending []cacheKey // entries in local that aren't yet in shared
+	misses  int
+	stopped bool
+}
+
+func (w *worker) blink(stone Stone, blinks int) (int, error) {
+	if blinks == 0 {
+		return 1, nil
+	}
+	var sum int
+	for _, s := range w.rules.Step(stone) {
+		n, err := w.cachedBlink(s, blinks-1)
+		if err != nil {
+			return 0, err
+		}
+		if sum, err = checked.Add(sum, n); err != nil {
+			return 0, err
+		}
+	}
+	return sum, nil
+}
+
+func (w *worker) cachedBlink(stone Stone, blinks int) (int, error) {
+	key := cacheKey{stone, blinks}
+	if out, ok := w.local[key]; ok {
+		return out, nil
+	}
+	// Checking the context is relatively slow, so only do it every so
+	// often. Once cancelled, stop storing results, which are now wrong.
+	w.misses++
+	if w.stopped || (w.misses%1024 == 0 && w.ctx.Err() != nil) {
+		w.stopped = true
+		return 0, nil
+	}
+	out, err := w.blink(stone, blinks)
+	if err != nil {
+		return 0, err
+	}
+	if !w.stopped {
+		w.local[key] = out
+		w.pending = append(w.pending, key)
+	}
+	return out, nil
+}
+
+// flush merges the worker's new entries into the shared cache.
+func (w *worker) flush() {
+	if w.stopped {
+		return
+	}
+	w.shared.Merge(w.local, w.pending)
+	w.pending = w.pending[:0]
+}
+
+// CountContext is like Count, but shares the stones between up to
+// parallelism workers. Each worker has its own cache, which it merges into the
+// evaluator's cache after each stone. The workers' own caches aren't limited
+// in size, even if the evaluator's cache is. If ctx is cancelled before all the
+// stones are counted, it returns the context's error. Like Count, it returns
+// checked.ErrOverflow if there are too many stones to count in an int.
+func (e *Evaluator) CountContext(ctx context.Context, stones []Stone, blinks, parallelism int) (int, error) {
+	parallelism = max(1, min(parallelism, len(stones)))
+	in := make(chan Stone)
+	type result struct {
+		sum     int
+		stopped bool
+		err     error
+	}
+	out := make(chan result, parallelism)
 
-	// Create workers
 	var wg sync.WaitGroup
-	for range 6 {
+	for range parallelism {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			for n := range in {
-				out <- cachedBlink(n, 75)
+			w := &worker{
+				ctx:    ctx,
+				rules:  e.Rules,
+				shared: e.Cache,
+				local:  make(map[cacheKey]int),
+			}
+			var r result
+			for stone := range in {
+				// Keep taking stones after an error, so that
+				// the sender isn't left waiting.
+				if r.err != nil {
+					continue
+				}
+				n, ok := e.Cache.Get(cacheKey{stone, blinks})
+				var err error
+				if !ok {
+					n, err = w.cachedBlink(stone, blinks)
+				}
+				if err == nil {
+					r.sum, err = checked.Add(r.sum, n)
+				}
+				r.err = err
+				w.flush()
 			}
+			r.stopped = w.stopped
+			out <- r
 		}()
 	}
-	go func() {
-		wg.Wait()
-		close(out)
-	}()
 
 	// Send input to workers
-	for _, n := range stones {
-		in <- n
-	}
-	close(in)
+	var stopped bool
+	go func() {
+		defer close(in)
+		for _, stone := range stones {
+			select {
+			case in <- stone:
+			case <-ctx.Done():
+				stopped = true
+				return
+			}
+		}
+	}()
+	wg.Wait()
+	close(out)
 
 	// Collect results
 	var sum int
-	for n := range out {
-		sum += n
+	var err error
+	for r := range out {
+		stopped = stopped || r.stopped
+		if err == nil {
+			err = r.err
+		}
+		if err == nil {
+			sum, err = checked.Add(sum, r.sum)
+		}
+	}
+	if stopped {
+		return 0, ctx.Err()
 	}
-	return sum
+	if err != nil {
+		return 0, err
+	}
+	return sum, nil
+}
+
+// Part2 counts the stones with a single worker. There are only a few
+// thousand distinct stones, so extra workers mostly repeat each other's work;
+// see BenchmarkParallel.
+func Part2(stones []int) (int, error) {
+	return Count(stones, 75, NewCache(0))
 }
 
 func main() {
-	stones, err := parseInput(os.Args[1])
+	blinks := flag.Int("blinks", 0, "count the stones after this many blinks, instead of solving both parts")
+	counts := flag.Bool("counts", false, "track counts of each stone value instead of following each stone")
+	limit := flag.Int("cache-limit", 0, "the most entries to keep in the cache, or 0 for no limit")
+	parallel := flag.Int("parallel", 1, "the number of workers to count stones with")
+	timeout := flag.Duration("timeout", 0, "give up after this long, or 0 for no limit")
+	flag.Parse()
+
+	stones, err := parseInput(flag.Arg(0))
 	if err != nil {
 		log.Fatal(err)
 	}
-	fmt.Printf("Part 2: %d\n", Part2(stones))
+	if *blinks == 0 {
+		part1, err := Part1(stones, 25)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Part 1: %d\n", part1)
+		part2, err := Part2(stones)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Part 2: %d\n", part2)
+		return
+	}
+	if *counts {
+		sum, err := CountMap(stones, *blinks)
+		if err != nil {
+			log.Fatalf("%d blinks: %v", *blinks, err)
+		}
+		fmt.Printf("%d blinks: %d\n", *blinks, sum)
+		return
+	}
+	ctx := context.Background()
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+	e := NewEvaluator(PuzzleRules, NewCache(*limit))
+	sum, err := e.CountContext(ctx, toStones(stones), *blinks, *parallel)
+	if err != nil {
+		log.Fatalf("%d blinks: %v", *blinks, err)
+	}
+	fmt.Printf("%d blinks: %d\n", *blinks, sum)
+	fmt.Printf("Cache: %s\n", e.Cache.Stats())
 }
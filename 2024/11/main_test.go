@@ -1,9 +1,12 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"testing"
+	"time"
 
+	"github.com/TonyRippy/advent-of-code/2024/checked"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -21,7 +24,9 @@ func TestPart1(t *testing.T) {
 		t.Run(fmt.Sprintf("%s/%d", tc.filename, tc.blinks), func(t *testing.T) {
 			stones, err := parseInput(tc.filename)
 			require.NoError(t, err)
-			assert.Equal(t, tc.expected, Part1(stones, tc.blinks))
+			n, err := Part1(stones, tc.blinks)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, n)
 		})
 	}
 }
@@ -36,7 +41,185 @@ func TestPart2(t *testing.T) {
 		t.Run(tc.filename, func(t *testing.T) {
 			v, err := parseInput(tc.filename)
 			require.NoError(t, err)
-			assert.Equal(t, tc.expected, Part2(v))
+			n, err := Part2(v)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, n)
+		})
+	}
+}
+
+func TestCache(t *testing.T) {
+	c := NewCache(2)
+	c.Put(cacheKey{NewStone(1), 1}, 10)
+	c.Put(cacheKey{NewStone(2), 1}, 20)
+	v, ok := c.Get(cacheKey{NewStone(1), 1})
+	assert.True(t, ok)
+	assert.Equal(t, 10, v)
+	// {2, 1} is now the least recently used
+	c.Put(cacheKey{NewStone(3), 1}, 30)
+	_, ok = c.Get(cacheKey{NewStone(2), 1})
+	assert.False(t, ok)
+	_, ok = c.Get(cacheKey{NewStone(3), 1})
+	assert.True(t, ok)
+	assert.Equal(t, CacheStats{Hits: 2, Misses: 1, Evictions: 1, Size: 2}, c.Stats())
+}
+
+func TestCount(t *testing.T) {
+	stones, err := parseInput("input.txt")
+	require.NoError(t, err)
+	for _, limit := range []int{0, 1, 100} {
+		t.Run(fmt.Sprintf("limit=%d", limit), func(t *testing.T) {
+			cache := NewCache(limit)
+			n, err := Count(stones, 25, cache)
+			require.NoError(t, err)
+			assert.Equal(t, 217812, n)
+			stats := cache.Stats()
+			if limit > 0 {
+				assert.Equal(t, limit, stats.Size)
+				assert.Positive(t, stats.Evictions)
+			} else {
+				assert.Zero(t, stats.Evictions)
+			}
+		})
+	}
+
+	// An unlimited cache keeps everything it is given, so it holds more
+	// than a limited one and never evicts, and counting again only hits.
+	cache := NewCache(0)
+	_, err = Count(stones, 25, cache)
+	require.NoError(t, err)
+	limited := NewCache(100)
+	_, err = Count(stones, 25, limited)
+	require.NoError(t, err)
+	before := cache.Stats()
+	assert.Greater(t, before.Size, limited.Stats().Size)
+	assert.Equal(t, before.Misses, before.Size)
+	assert.Zero(t, before.Evictions)
+	_, err = Count(stones, 25, cache)
+	require.NoError(t, err)
+	after := cache.Stats()
+	assert.Greater(t, after.Hits, before.Hits)
+	assert.Equal(t, before.Misses, after.Misses)
+}
+
+func TestCountMap(t *testing.T) {
+	for _, tc := range []struct {
+		filename string
+		blinks   int
+		expected int
+	}{
+		{"test.txt", 6, 22},
+		{"test.txt", 25, 55312},
+		{"input.txt", 25, 217812},
+		{"input.txt", 75, 259112729857522},
+	} {
+		stones, err := parseInput(tc.filename)
+		require.NoError(t, err)
+		n, err := CountMap(stones, tc.blinks)
+		require.NoError(t, err)
+		assert.Equal(t, tc.expected, n, "%s, %d blinks", tc.filename, tc.blinks)
+	}
+}
+
+func TestCountOverflow(t *testing.T) {
+	stones, err := parseInput("input.txt")
+	require.NoError(t, err)
+	_, err = Count(stones, 500, NewCache(0))
+	assert.ErrorIs(t, err, checked.ErrOverflow)
+	_, err = CountMap(stones, 500)
+	assert.ErrorIs(t, err, checked.ErrOverflow)
+	e := NewEvaluator(PuzzleRules, NewCache(0))
+	_, err = e.CountContext(context.Background(), toStones(stones), 500, 4)
+	assert.ErrorIs(t, err, checked.ErrOverflow)
+
+	// The count fits right up until it doesn't.
+	var last int
+	for blinks := 75; ; blinks++ {
+		n, err := CountMap(stones, blinks)
+		if err != nil {
+			assert.ErrorIs(t, err, checked.ErrOverflow)
+			assert.Greater(t, blinks, 100)
+			break
+		}
+		assert.Greater(t, n, last)
+		last = n
+	}
+}
+
+func BenchmarkBlink(b *testing.B) {
+	stones, err := parseInput("input.txt")
+	require.NoError(b, err)
+	for _, blinks := range []int{25, 50, 75} {
+		b.Run(fmt.Sprintf("recursive/%d", blinks), func(b *testing.B) {
+			for range b.N {
+				Count(stones, blinks, NewCache(0))
+			}
+		})
+		b.Run(fmt.Sprintf("counts/%d", blinks), func(b *testing.B) {
+			for range b.N {
+				CountMap(stones, blinks)
+			}
+		})
+	}
+}
+
+func TestCountContext(t *testing.T) {
+	stones, err := parseInput("input.txt")
+	require.NoError(t, err)
+	for _, parallelism := range []int{0, 1, 2, 8, 100} {
+		t.Run(fmt.Sprint(parallelism), func(t *testing.T) {
+			e := NewEvaluator(PuzzleRules, NewCache(0))
+			sum, err := e.CountContext(context.Background(), toStones(stones), 75, parallelism)
+			require.NoError(t, err)
+			assert.Equal(t, 259112729857522, sum)
+
+			// The workers' results end up in the shared cache, which
+			// they only look at once for each stone they are given.
+			before := e.Cache.Stats()
+			assert.Positive(t, before.Size)
+			assert.Equal(t, CacheStats{Misses: len(stones), Size: before.Size}, before)
+			sum, err = e.CountContext(context.Background(), toStones(stones), 75, parallelism)
+			require.NoError(t, err)
+			assert.Equal(t, 259112729857522, sum)
+			assert.Equal(t, CacheStats{Hits: len(stones), Misses: len(stones), Size: before.Size}, e.Cache.Stats())
+		})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	e := NewEvaluator(PuzzleRules, NewCache(0))
+	_, err = e.CountContext(ctx, toStones(stones), 75, 4)
+	assert.ErrorIs(t, err, context.Canceled)
+	// Nothing is cached once the context has been cancelled. Counting this
+	// many different stones takes much longer than the timeout, but not so
+	// many that the count overflows.
+	many := make([]int, 10000)
+	for i := range many {
+		many[i] = i
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	_, err = e.CountContext(ctx, toStones(many), 75, 1)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	sum, err := e.CountContext(context.Background(), toStones(stones), 25, 4)
+	require.NoError(t, err)
+	assert.Equal(t, 217812, sum)
+}
+
+// BenchmarkParallel compares counting the stones in Part 2 with different
+// numbers of workers, each starting from an empty cache. There are only a
+// few thousand distinct stones, so the workers mostly end up computing the
+// same counts, and extra workers tend to make it slower rather than faster.
+// That is why Part2 uses a single worker, and -parallel defaults to 1.
+func BenchmarkParallel(b *testing.B) {
+	stones, err := parseInput("input.txt")
+	require.NoError(b, err)
+	for _, parallelism := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprint(parallelism), func(b *testing.B) {
+			for range b.N {
+				e := NewEvaluator(PuzzleRules, NewCache(0))
+				e.CountContext(context.Background(), toStones(stones), 75, parallelism)
+			}
 		})
 	}
 }
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"errors"
+	"math/big"
+	"strconv"
+
+	"github.com/TonyRippy/advent-of-code/2024/checked"
+)
+
+// Stone is the number engraved on a stone. Numbers that fit in an int are
+// kept as one. Larger numbers are kept as a decimal string, rather than a
+// *big.Int, so that stones can still be compared and used as map keys.
+type Stone struct {
+	n   int
+	big string // set if the number doesn't fit in an int
+}
+
+func NewStone(n int) Stone {
+	return Stone{n: n}
+}
+
+// bigStone returns the stone for x, using an int if it fits.
+func bigStone(x *big.Int) Stone {
+	if n, err := checked.Int(x); err == nil {
+		return Stone{n: n}
+	}
+	return Stone{big: x.String()}
+}
+
+// parseStone returns the stone for a string of digits. Leading zeros are
+// dropped.
+func parseStone(s string) Stone {
+	n, err := strconv.Atoi(s)
+	if err == nil {
+		return Stone{n: n}
+	}
+	if !errors.Is(err, strconv.ErrRange) {
+		panic("invalid stone " + s)
+	}
+	x, _ := new(big.Int).SetString(s, 10)
+	return bigStone(x)
+}
+
+// IsBig returns true if the number doesn't fit in an int.
+func (s Stone) IsBig() bool {
+	return s.big != ""
+}
+
+// Int returns the number as an int, if it fits.
+func (s Stone) Int() (int, bool) {
+	return s.n, !s.IsBig()
+}
+
+func (s Stone) Big() *big.Int {
+	if s.IsBig() {
+		x, _ := new(big.Int).SetString(s.big, 10)
+		return x
+	}
+	return big.NewInt(int64(s.n))
+}
+
+func (s Stone) String() string {
+	if s.IsBig() {
+		return s.big
+	}
+	return strconv.Itoa(s.n)
+}
+
+// Digits returns the number of decimal digits in the number.
+func (s Stone) Digits() int {
+	if s.IsBig() {
+		return len(s.big)
+	}
+	n := s.n
+	digits := 1
+	for n >= 10 {
+		n /= 10
+		digits++
+	}
+	return digits
+}
+
+func toStones(ns []int) []Stone {
+	stones := make([]Stone, len(ns))
+	for i, n := range ns {
+		stones[i] = NewStone(n)
+	}
+	return stones
+}
+
+// Predicate decides if a rule applies to a stone.
+type Predicate func(Stone) bool
+
+// Transform returns the stones that a stone turns into.
+type Transform func(Stone) []Stone
+
+// Rule changes the stones that match a predicate.
+type Rule struct {
+	Name  string
+	Match Predicate
+	Apply Transform
+}
+
+// Rules are tried in order, and only the first rule that matches a stone
+// applies. Stones that don't match any rule are left unchanged.
+type Rules []Rule
+
+// Step returns the stones that s turns into when you blink.
+func (rs Rules) Step(s Stone) []Stone {
+	for _, r := range rs {
+		if r.Match(s) {
+			return r.Apply(s)
+		}
+	}
+	return []Stone{s}
+}
+
+// PuzzleRules are the rules from the puzzle.
+var PuzzleRules = Rules{
+	{"zero", InRange(0, 0), SetTo(1)},
+	{"even digits", EvenDigits, SplitDigits},
+	{"multiply", Always, MultiplyBy(2024)},
+}
+
+// Always matches every stone.
+func Always(Stone) bool {
+	return true
+}
+
+// EvenDigits matches stones with an even number of digits.
+func EvenDigits(s Stone) bool {
+	return s.Digits()%2 == 0
+}
+
+// OddDigits matches stones with an odd number of digits.
+func OddDigits(s Stone) bool {
+	return s.Digits()%2 == 1
+}
+
+// InRange matches stones with a number from lo to hi, inclusive.
+func InRange(lo, hi int) Predicate {
+	return func(s Stone) bool {
+		n, ok := s.Int()
+		return ok && n >= lo && n <= hi
+	}
+}
+
+// SetTo replaces a stone with one engraved with n.
+func SetTo(n int) Transform {
+	return func(Stone) []Stone {
+		return []Stone{NewStone(n)}
+	}
+}
+
+// SplitDigits splits a stone into two, with the left and right halves of its
+// digits. It should only be applied to stones with an even number of digits.
+func SplitDigits(s Stone) []Stone {
+	if n, ok := s.Int(); ok {
+		pow := pow10(s.Digits() / 2)
+		return []Stone{NewStone(n / pow), NewStone(n % pow)}
+	}
+	mid := len(s.big) / 2
+	return []Stone{parseStone(s.big[:mid]), parseStone(s.big[mid:])}
+}
+
+// MultiplyBy replaces a stone with one engraved with its number times k.
+// If the result doesn't fit in an int, it is calculated using big integers.
+func MultiplyBy(k int) Transform {
+	bigK := big.NewInt(int64(k))
+	return func(s Stone) []Stone {
+		if n, ok := s.Int(); ok {
+			if v, err := checked.Mul(n, k); err == nil {
+				return []Stone{NewStone(v)}
+			}
+		}
+		return []Stone{bigStone(new(big.Int).Mul(s.Big(), bigK))}
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// simulate follows every stone one blink at a time using big integers. It is
+// only usable for a small number of blinks.
+func simulate(stones []*big.Int, blinks int) []*big.Int {
+	for range blinks {
+		var next []*big.Int
+		for _, s := range stones {
+			digits := s.String()
+			switch {
+			case s.Sign() == 0:
+				next = append(next, big.NewInt(1))
+			case len(digits)%2 == 0:
+				l, _ := new(big.Int).SetString(digits[:len(digits)/2], 10)
+				r, _ := new(big.Int).SetString(digits[len(digits)/2:], 10)
+				next = append(next, l, r)
+			default:
+				next = append(next, new(big.Int).Mul(s, big.NewInt(2024)))
+			}
+		}
+		stones = next
+	}
+	return stones
+}
+
+func TestStone(t *testing.T) {
+	for _, tc := range []struct {
+		s      string
+		digits int
+		big    bool
+	}{
+		{"0", 1, false},
+		{"9", 1, false},
+		{"10", 2, false},
+		{"9223372036854775807", 19, false},
+		{"9223372036854775808", 19, true},
+		{"20240000000000000000000", 23, true},
+	} {
+		s := parseStone(tc.s)
+		assert.Equal(t, tc.s, s.String())
+		assert.Equal(t, tc.digits, s.Digits(), tc.s)
+		assert.Equal(t, tc.big, s.IsBig(), tc.s)
+		assert.Equal(t, tc.s, s.Big().String())
+	}
+	// Leading zeros are dropped, and small halves of big numbers are ints.
+	assert.Equal(t, []Stone{NewStone(1000000000), NewStone(1)}, SplitDigits(parseStone("10000000000000000001")))
+	assert.Equal(t, NewStone(7), bigStone(big.NewInt(7)))
+}
+
+func TestOverflow(t *testing.T) {
+	// 10^18 has an odd number of digits, so it is multiplied by 2024, which
+	// doesn't fit in an int.
+	start := parseStone("1000000000000000000")
+	next := PuzzleRules.Step(start)
+	require.Len(t, next, 1)
+	assert.True(t, next[0].IsBig())
+	assert.Equal(t, "2024000000000000000000", next[0].String())
+
+	for blinks := range 12 {
+		t.Run(fmt.Sprint(blinks), func(t *testing.T) {
+			want := len(simulate([]*big.Int{start.Big()}, blinks))
+			e := NewEvaluator(PuzzleRules, NewCache(0))
+			n, err := e.Count([]Stone{start}, blinks)
+			require.NoError(t, err)
+			assert.Equal(t, want, n)
+			n, err = CountMapWith(PuzzleRules, []Stone{start}, blinks)
+			require.NoError(t, err)
+			assert.Equal(t, want, n)
+		})
+	}
+}
+
+func TestRules(t *testing.T) {
+	// Small numbers are tripled, odd-length numbers above that are halved
+	// into their digits, and the rest are left alone.
+	rules := Rules{
+		{"small", InRange(0, 99), MultiplyBy(3)},
+		{"odd digits", OddDigits, func(s Stone) []Stone {
+			var out []Stone
+			for _, c := range s.String() {
+				out = append(out, NewStone(int(c-'0')))
+			}
+			return out
+		}},
+	}
+	assert.Equal(t, []Stone{NewStone(6)}, rules.Step(NewStone(2)))
+	assert.Equal(t, []Stone{NewStone(1), NewStone(2), NewStone(3)}, rules.Step(NewStone(123)))
+	assert.Equal(t, []Stone{NewStone(1234)}, rules.Step(NewStone(1234)))
+
+	// Brute force the same rules to check the evaluators.
+	stones := toStones([]int{0, 1, 7, 125, 4096})
+	list := stones
+	for blinks := range 15 {
+		e := NewEvaluator(rules, NewCache(0))
+		n, err := e.Count(stones, blinks)
+		require.NoError(t, err)
+		assert.Equal(t, len(list), n, "%d blinks", blinks)
+		n, err = CountMapWith(rules, stones, blinks)
+		require.NoError(t, err)
+		assert.Equal(t, len(list), n, "%d blinks", blinks)
+		var next []Stone
+		for _, s := range list {
+			next = append(next, rules.Step(s)...)
+		}
+		list = next
+	}
+}
+
+func TestPuzzleRules(t *testing.T) {
+	stones, err := parseInput("test.txt")
+	require.NoError(t, err)
+	var want []*big.Int
+	for _, s := range stones {
+		want = append(want, big.NewInt(int64(s)))
+	}
+	want = simulate(want, 6)
+
+	list := toStones(stones)
+	for range 6 {
+		var next []Stone
+		for _, s := range list {
+			next = append(next, PuzzleRules.Step(s)...)
+		}
+		list = next
+	}
+	require.Len(t, list, len(want))
+	for i := range list {
+		assert.Equal(t, want[i].String(), list[i].String())
+	}
+}
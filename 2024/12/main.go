@@ -2,6 +2,9 @@ package main
 
 import (
 	"bufio"
+	"flag"
+	"fmt"
+	"iter"
 	"log"
 	"os"
 	"strings"
@@ -16,83 +19,107 @@ type Map struct {
 	Width   int
 	Height  int
 	Squares [][]Square
+	regions []*Region
 }
 
-func (m *Map) assignIds() {
-	changed := true
-	for changed {
-		changed = false
-		for y, row := range m.Squares {
-			for x, me := range row {
-				min := me.id
-				if x > 0 && m.Squares[y][x-1].c == me.c {
-					id := m.Squares[y][x-1].id
-					if id < min {
-						min = id
-					}
-				}
-				if x < m.Width-1 && m.Squares[y][x+1].c == me.c {
-					id := m.Squares[y][x+1].id
-					if id < min {
-						min = id
-					}
-				}
-				if y > 0 && m.Squares[y-1][x].c == me.c {
-					id := m.Squares[y-1][x].id
-					if id < min {
-						min = id
-					}
-				}
-				if y < m.Height-1 && m.Squares[y+1][x].c == me.c {
-					id := m.Squares[y+1][x].id
-					if id < min {
-						min = id
-					}
-				}
-				if min != me.id {
-					m.Squares[y][x].id = min
-					changed = true
-				}
-			}
-		}
-	}
+type Point struct {
+	X, Y int
 }
 
-func (m *Map) Part1() int {
-	perimeter := make(map[int]int)
-	area := make(map[int]int)
+// Region is a group of connected squares with the same plant.
+type Region struct {
+	ID        int
+	Plant     rune
+	Cells     []Point
+	Area      int
+	Perimeter int
+	Sides     int
+}
+
+// labelRegions gives each square the id of its region, flood filling each
+// region from the first square found in it, and then measures the regions.
+func (m *Map) labelRegions() {
+	m.regions = nil
+	for y, row := range m.Squares {
+		for x := range row {
+			m.Squares[y][x].id = 0
+		}
+	}
+	var stack []Point
 	for y, row := range m.Squares {
 		for x, square := range row {
-			if a, ok := area[square.id]; !ok {
-				area[square.id] = 1
-			} else {
-				area[square.id] = a + 1
+			if square.id != 0 {
+				continue
 			}
-			var boundaries int
-			if x == 0 || row[x-1].id != square.id {
-				boundaries += 1
+			r := &Region{
+				ID:    len(m.regions) + 1,
+				Plant: square.c,
 			}
-			if x == (m.Width-1) || row[x+1].id != square.id {
-				boundaries += 1
-			}
-			if y == 0 || m.Squares[y-1][x].id != square.id {
-				boundaries += 1
+			m.Squares[y][x].id = r.ID
+			stack = append(stack[:0], Point{x, y})
+			for len(stack) > 0 {
+				p := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				r.Cells = append(r.Cells, p)
+				for _, n := range []Point{{p.X - 1, p.Y}, {p.X + 1, p.Y}, {p.X, p.Y - 1}, {p.X, p.Y + 1}} {
+					if n.X < 0 || n.X >= m.Width || n.Y < 0 || n.Y >= m.Height {
+						continue
+					}
+					if next := &m.Squares[n.Y][n.X]; next.id == 0 && next.c == r.Plant {
+						next.id = r.ID
+						stack = append(stack, n)
+					}
+				}
 			}
-			if y == (m.Height-1) || m.Squares[y+1][x].id != square.id {
-				boundaries += 1
+			// Now that the whole region is labelled, the squares around it
+			// can be told apart from the region itself.
+			r.Area = len(r.Cells)
+			for _, p := range r.Cells {
+				r.Perimeter += m.boundaries(p.X, p.Y)
+				r.Sides += m.corners(p.X, p.Y)
 			}
-			if p, ok := perimeter[square.id]; !ok {
-				perimeter[square.id] = boundaries
-			} else {
-				perimeter[square.id] = p + boundaries
+			m.regions = append(m.regions, r)
+		}
+	}
+}
+
+// Regions returns the regions of the map, in the order of the first square of
+// each region, reading left to right and top to bottom.
+func (m *Map) Regions() iter.Seq[*Region] {
+	return func(yield func(*Region) bool) {
+		for _, r := range m.regions {
+			if !yield(r) {
+				return
 			}
 		}
 	}
+}
+
+// boundaries returns the number of edges of a square that are on the
+// perimeter of its region.
+func (m *Map) boundaries(x, y int) int {
+	square := m.Squares[y][x]
+	row := m.Squares[y]
+	var boundaries int
+	if x == 0 || row[x-1].id != square.id {
+		boundaries += 1
+	}
+	if x == (m.Width-1) || row[x+1].id != square.id {
+		boundaries += 1
+	}
+	if y == 0 || m.Squares[y-1][x].id != square.id {
+		boundaries += 1
+	}
+	if y == (m.Height-1) || m.Squares[y+1][x].id != square.id {
+		boundaries += 1
+	}
+	return boundaries
+}
+
+func (m *Map) Part1() int {
 	var total int
-	for id, area := range area {
-		p := perimeter[id]
-		price := area * p
-		total += price
+	for r := range m.Regions() {
+		total += r.Area * r.Perimeter
 	}
 	return total
 }
@@ -161,29 +188,12 @@ func (m *Map) corners(x, y int) int {
 	return corner
 }
 
+// Part2 prices each region by its number of sides. A region has as many sides
+// as it has corners.
 func (m *Map) Part2() int {
-	corners := make(map[int]int)
-	area := make(map[int]int)
-	for y, row := range m.Squares {
-		for x, square := range row {
-			if a, ok := area[square.id]; !ok {
-				area[square.id] = 1
-			} else {
-				area[square.id] = a + 1
-			}
-			c := m.corners(x, y)
-			if cs, ok := corners[square.id]; !ok {
-				corners[square.id] = c
-			} else {
-				corners[square.id] = cs + c
-			}
-		}
-	}
 	var total int
-	for id, area := range area {
-		sides := corners[id]
-		price := area * sides
-		total += price
+	for r := range m.Regions() {
+		total += r.Area * r.Sides
 	}
 	return total
 }
@@ -196,13 +206,11 @@ func parseInput(filename string) (*Map, error) {
 	defer file.Close()
 
 	var squares [][]Square
-	nextid := 1
 	scanner := bufio.NewScanner(file)
 	for scanner.Scan() {
 		var line []Square
 		for _, c := range strings.TrimSpace(scanner.Text()) {
-			line = append(line, Square{nextid, c})
-			nextid++
+			line = append(line, Square{0, c})
 		}
 		squares = append(squares, line)
 	}
@@ -214,13 +222,25 @@ func parseInput(filename string) (*Map, error) {
 		Height:  len(squares),
 		Squares: squares,
 	}
-	m.assignIds()
+	m.labelRegions()
 	return m, nil
 }
 
 func main() {
-	_, err := parseInput(os.Args[1])
+	svg := flag.Bool("svg", false, "draw the map as an SVG image, with the price of each region")
+	scale := flag.Int("scale", 20, "the size of each square in the SVG image")
+	flag.Parse()
+
+	m, err := parseInput(flag.Arg(0))
 	if err != nil {
 		log.Fatal(err)
 	}
+	if *svg {
+		if err := writeSVG(os.Stdout, m, *scale); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	fmt.Printf("Part 1: %d\n", m.Part1())
+	fmt.Printf("Part 2: %d\n", m.Part2())
 }
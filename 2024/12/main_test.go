@@ -1,6 +1,10 @@
 package main
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -43,3 +47,138 @@ func TestPart2(t *testing.T) {
 		})
 	}
 }
+
+func TestRegions(t *testing.T) {
+	m, err := parseInput("test1a.txt")
+	require.NoError(t, err)
+	var got []string
+	for r := range m.Regions() {
+		got = append(got, fmt.Sprintf("%c: area %d, perimeter %d, sides %d", r.Plant, r.Area, r.Perimeter, r.Sides))
+	}
+	assert.Equal(t, []string{
+		"A: area 4, perimeter 10, sides 4",
+		"B: area 4, perimeter 8, sides 4",
+		"C: area 4, perimeter 10, sides 8",
+		"D: area 1, perimeter 4, sides 4",
+		"E: area 3, perimeter 8, sides 4",
+	}, got)
+
+	// The X regions are all separate, even though they have the same plant.
+	m, err = parseInput("test1b.txt")
+	require.NoError(t, err)
+	counts := make(map[rune]int)
+	for r := range m.Regions() {
+		counts[r.Plant]++
+		for _, p := range r.Cells {
+			assert.Equal(t, r.ID, m.Squares[p.Y][p.X].id)
+			assert.Equal(t, r.Plant, m.Squares[p.Y][p.X].c)
+		}
+	}
+	assert.Equal(t, map[rune]int{'O': 1, 'X': 4}, counts)
+}
+
+// snake returns an n×n map with an A region that winds back and forth across
+// the whole grid, separating rows of B.
+func snake(n int) string {
+	var b strings.Builder
+	for y := range n {
+		for x := range n {
+			if y%2 == 0 || (y%4 == 1 && x == n-1) || (y%4 == 3 && x == 0) {
+				b.WriteByte('A')
+			} else {
+				b.WriteByte('B')
+			}
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+func TestSnake(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "snake.txt")
+	require.NoError(t, os.WriteFile(filename, []byte(snake(200)), 0o644))
+	m, err := parseInput(filename)
+	require.NoError(t, err)
+	var regions []*Region
+	for r := range m.Regions() {
+		regions = append(regions, r)
+	}
+	require.Len(t, regions, 101)
+	assert.Equal(t, 'A', regions[0].Plant)
+	assert.Equal(t, 100*200+100, regions[0].Area)
+	for _, r := range regions[1:] {
+		assert.Equal(t, 'B', r.Plant)
+		assert.Equal(t, 199, r.Area)
+		assert.Equal(t, 4, r.Sides)
+	}
+}
+
+func BenchmarkLabelRegions(b *testing.B) {
+	filename := filepath.Join(b.TempDir(), "snake.txt")
+	require.NoError(b, os.WriteFile(filename, []byte(snake(500)), 0o644))
+	m, err := parseInput(filename)
+	require.NoError(b, err)
+	for range b.N {
+		m.labelRegions()
+	}
+}
+
+func TestPolygon(t *testing.T) {
+	m, err := parseInput("test1b.txt")
+	require.NoError(t, err)
+	var regions []*Region
+	for r := range m.Regions() {
+		regions = append(regions, r)
+	}
+	poly := regions[0].Polygon()
+	assert.Equal(t, []Point{{5, 0}, {5, 5}, {0, 5}, {0, 0}}, poly.Outer)
+	assert.Len(t, poly.Holes, 4)
+	assert.ElementsMatch(t, []Point{{2, 1}, {1, 1}, {1, 2}, {2, 2}}, poly.Holes[0])
+	assert.Equal(t, 21, poly.Area())
+	assert.Equal(t, 20, poly.Sides())
+
+	// The A region touches itself diagonally in the middle, between the two
+	// B regions. They make a single hole, whose outline passes through the
+	// middle twice.
+	//   AAAAAA
+	//   AAABBA
+	//   AAABBA
+	//   ABBAAA
+	//   ABBAAA
+	//   AAAAAA
+	m, err = parseInput("test2b.txt")
+	require.NoError(t, err)
+	for r := range m.Regions() {
+		if r.Plant == 'A' {
+			poly := r.Polygon()
+			require.Len(t, poly.Holes, 1)
+			assert.Equal(t, []Point{{1, 5}, {3, 5}, {3, 3}, {5, 3}, {5, 1}, {3, 1}, {3, 3}, {1, 3}}, poly.Holes[0])
+			assert.Equal(t, 12, poly.Sides())
+		}
+	}
+
+	for _, filename := range []string{"test1a.txt", "test1b.txt", "test1c.txt", "test2a.txt", "test2b.txt", "input.txt"} {
+		t.Run(filename, func(t *testing.T) {
+			m, err := parseInput(filename)
+			require.NoError(t, err)
+			for r := range m.Regions() {
+				poly := r.Polygon()
+				require.NotEmpty(t, poly.Outer)
+				assert.Equal(t, r.Sides, poly.Sides(), "%c region at %v", r.Plant, r.Cells[0])
+				assert.Equal(t, r.Area, poly.Area(), "%c region at %v", r.Plant, r.Cells[0])
+			}
+		})
+	}
+}
+
+func TestSVG(t *testing.T) {
+	m, err := parseInput("test1a.txt")
+	require.NoError(t, err)
+	var b strings.Builder
+	require.NoError(t, writeSVG(&b, m, 10))
+	svg := b.String()
+	assert.True(t, strings.HasPrefix(svg, `<svg xmlns="http://www.w3.org/2000/svg" width="40" height="40"`))
+	assert.Contains(t, svg, `<path d="M40 0 L40 10 L0 10 L0 0 Z"`)
+	assert.Contains(t, svg, `>C 4×8=32</text>`)
+	assert.Equal(t, 5, strings.Count(svg, "<path "))
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Polygon is the outline of a region, as points on the grid lines between
+// squares. The point {X, Y} is the top left corner of the square at {X, Y}.
+// Only the corners are included, so every point is where a side ends.
+type Polygon struct {
+	// Outer goes clockwise around the outside of the region.
+	Outer []Point
+	// Holes go anticlockwise around the other regions inside this one.
+	Holes [][]Point
+}
+
+// Sides returns the number of sides of the polygon, including the sides of
+// any holes.
+func (p Polygon) Sides() int {
+	sides := len(p.Outer)
+	for _, h := range p.Holes {
+		sides += len(h)
+	}
+	return sides
+}
+
+// Area returns the area inside the polygon, not including any holes.
+func (p Polygon) Area() int {
+	area := shoelace(p.Outer)
+	for _, h := range p.Holes {
+		area += shoelace(h)
+	}
+	return area
+}
+
+// shoelace returns the area of a simple polygon, which is negative if it
+// goes anticlockwise.
+func shoelace(points []Point) int {
+	var sum int
+	for i, p := range points {
+		q := points[(i+1)%len(points)]
+		sum += p.X*q.Y - q.X*p.Y
+	}
+	return sum / 2
+}
+
+// Polygon traces the outline of the region. A region can touch itself at a
+// corner, where two of its squares meet diagonally. The outline never crosses
+// over itself there: it turns to follow the square it is already going around.
+// Each of those corners counts once for each square, the same as in corners.
+func (r *Region) Polygon() Polygon {
+	in := make(map[Point]bool, len(r.Cells))
+	for _, p := range r.Cells {
+		in[p] = true
+	}
+
+	// The edges between the region and its surroundings, directed so that
+	// the region is always on the right.
+	type edge struct{ from, dir Point }
+	var edges []edge
+	next := make(map[Point][]Point)
+	for _, p := range r.Cells {
+		for _, e := range []struct {
+			n         Point
+			from, dir Point
+		}{
+			{Point{p.X, p.Y - 1}, Point{p.X, p.Y}, Point{1, 0}},
+			{Point{p.X + 1, p.Y}, Point{p.X + 1, p.Y}, Point{0, 1}},
+			{Point{p.X, p.Y + 1}, Point{p.X + 1, p.Y + 1}, Point{-1, 0}},
+			{Point{p.X - 1, p.Y}, Point{p.X, p.Y + 1}, Point{0, -1}},
+		} {
+			if !in[e.n] {
+				edges = append(edges, edge{e.from, e.dir})
+				next[e.from] = append(next[e.from], e.dir)
+			}
+		}
+	}
+
+	var poly Polygon
+	used := make(map[edge]bool, len(edges))
+	for _, start := range edges {
+		if used[start] {
+			continue
+		}
+		var loop []Point
+		e := start
+		for !used[e] {
+			used[e] = true
+			to := Point{e.from.X + e.dir.X, e.from.Y + e.dir.Y}
+			dirs := next[to]
+			dir := dirs[0]
+			if len(dirs) > 1 {
+				// Turn right, to stay with the same square.
+				right := Point{-e.dir.Y, e.dir.X}
+				if dirs[1] == right {
+					dir = right
+				}
+			}
+			if dir != e.dir {
+				loop = append(loop, to)
+			}
+			e = edge{to, dir}
+		}
+		if shoelace(loop) > 0 {
+			poly.Outer = loop
+		} else {
+			poly.Holes = append(poly.Holes, loop)
+		}
+	}
+	return poly
+}
+
+// svgPath returns the SVG path data for the points, scaled up by scale.
+func svgPath(points []Point, scale int) string {
+	var b strings.Builder
+	for i, p := range points {
+		cmd := 'L'
+		if i == 0 {
+			cmd = 'M'
+		}
+		fmt.Fprintf(&b, "%c%d %d ", cmd, p.X*scale, p.Y*scale)
+	}
+	b.WriteString("Z")
+	return b.String()
+}
+
+// plantColor picks a fill colour for a plant, so that regions of the same
+// plant look alike.
+func plantColor(plant rune) string {
+	return fmt.Sprintf("hsl(%d, 60%%, 75%%)", (int(plant)*47)%360)
+}
+
+// writeSVG draws the map, with each region filled and labelled with its
+// price for Part 2. Each square is scale units across.
+func writeSVG(w io.Writer, m *Map, scale int) error {
+	var s strings.Builder
+	fmt.Fprintf(&s, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\" viewBox=\"0 0 %d %d\">\n",
+		m.Width*scale, m.Height*scale, m.Width*scale, m.Height*scale)
+	for r := range m.Regions() {
+		poly := r.Polygon()
+		d := svgPath(poly.Outer, scale)
+		for _, h := range poly.Holes {
+			d += " " + svgPath(h, scale)
+		}
+		fmt.Fprintf(&s, "  <path d=\"%s\" fill=\"%s\" fill-rule=\"evenodd\" stroke=\"black\"/>\n", d, plantColor(r.Plant))
+	}
+	// Labels go on top of all the regions, in the first square of each.
+	for r := range m.Regions() {
+		p := r.Cells[0]
+		fmt.Fprintf(&s, "  <text x=\"%d\" y=\"%d\" font-size=\"%d\" text-anchor=\"middle\" dominant-baseline=\"middle\">%c %d×%d=%d</text>\n",
+			p.X*scale+scale/2, p.Y*scale+scale/2, max(1, scale/3), r.Plant, r.Area, r.Sides, r.Area*r.Sides)
+	}
+	s.WriteString("</svg>\n")
+	_, err := io.WriteString(w, s.String())
+	return err
+}
@@ -1,11 +1,18 @@
 package main
 
 import (
+	"errors"
+	"flag"
+	"fmt"
 	"io"
 	"log"
+	"math/big"
 	"os"
 	"regexp"
 	"strconv"
+	"strings"
+
+	"github.com/TonyRippy/advent-of-code/2024/checked"
 )
 
 type Button struct {
@@ -21,28 +28,310 @@ type Machine struct {
 	p    Prize
 }
 
-func (m *Machine) findCost(p Prize) int {
-	// Solve the system of equations that will give the answer
-	a1 := m.a.dx * m.b.dy
-	p1 := p.x * m.b.dy
+// Rules are the parts of the puzzle that differ between Part 1 and Part 2.
+type Rules struct {
+	// CostA and CostB are the number of tokens it costs to press each
+	// button.
+	CostA, CostB int
+	// Limit is the most times each button can be pressed, or 0 for no
+	// limit.
+	Limit int
+	// Offset is added to both coordinates of every prize.
+	Offset int
+}
+
+var (
+	Part1Rules = Rules{CostA: 3, CostB: 1, Limit: 100}
+	Part2Rules = Rules{CostA: 3, CostB: 1, Offset: 10000000000000}
+)
+
+// Validate returns an error if the rules don't make sense. Buttons have to
+// cost something, since the cheapest way to win is only well defined when
+// pressing a button more never makes it cheaper.
+func (r Rules) Validate() error {
+	switch {
+	case r.CostA <= 0 || r.CostB <= 0:
+		return fmt.Errorf("button costs must be positive, not %d and %d", r.CostA, r.CostB)
+	case r.Limit < 0:
+		return fmt.Errorf("the press limit can't be negative: %d", r.Limit)
+	case r.Offset < 0:
+		return fmt.Errorf("the prize offset can't be negative: %d", r.Offset)
+	}
+	return nil
+}
+
+// The reasons a prize can't be won.
+var (
+	ErrUnwinnable     = errors.New("prize can't be won")
+	ErrNotInLine      = fmt.Errorf("%w: it isn't in line with the buttons", ErrUnwinnable)
+	ErrNotWhole       = fmt.Errorf("%w: no whole number of presses reaches it", ErrUnwinnable)
+	ErrNegative       = fmt.Errorf("%w: it needs a negative number of presses", ErrUnwinnable)
+	ErrTooManyPresses = fmt.Errorf("%w: it needs too many presses", ErrUnwinnable)
+)
+
+// Presses is the number of times each button is pressed.
+type Presses struct {
+	A, B int
+}
+
+// prize returns the position of the prize under the rules.
+func (m *Machine) prize(r Rules) (Prize, error) {
+	var p Prize
+	var err error
+	if p.x, err = checked.Add(m.p.x, r.Offset); err != nil {
+		return Prize{}, err
+	}
+	if p.y, err = checked.Add(m.p.y, r.Offset); err != nil {
+		return Prize{}, err
+	}
+	return p, nil
+}
+
+// solve returns the cheapest way to win the prize. If the prize can't be won,
+// the error says why, and wraps ErrUnwinnable. It returns checked.ErrOverflow
+// if any intermediate value doesn't fit in an int.
+func (m *Machine) solve(r Rules) (Presses, error) {
+	p, err := m.prize(r)
+	if err != nil {
+		return Presses{}, err
+	}
+	// Solve the system of equations that will give the answer, using
+	// Cramer's rule:
+	//   a*ax + b*bx = px
+	//   a*ay + b*by = py
+	d, err := cross(m.a.dx, m.a.dy, m.b.dx, m.b.dy)
+	if err != nil {
+		return Presses{}, err
+	}
+	if d == 0 {
+		// The buttons move the claw in the same direction, so there may
+		// be many ways to win, or none.
+		a, b, err := m.solveBig(r)
+		if err != nil {
+			return Presses{}, err
+		}
+		var out Presses
+		if out.A, err = checked.Int(a); err != nil {
+			return Presses{}, err
+		}
+		if out.B, err = checked.Int(b); err != nil {
+			return Presses{}, err
+		}
+		return out, nil
+	}
+	na, err := cross(p.x, p.y, m.b.dx, m.b.dy)
+	if err != nil {
+		return Presses{}, err
+	}
+	nb, err := cross(m.a.dx, m.a.dy, p.x, p.y)
+	if err != nil {
+		return Presses{}, err
+	}
+	if na%d != 0 || nb%d != 0 {
+		return Presses{}, ErrNotWhole
+	}
+	var out Presses
+	if out.A, err = checked.Div(na, d); err != nil {
+		return Presses{}, err
+	}
+	if out.B, err = checked.Div(nb, d); err != nil {
+		return Presses{}, err
+	}
+	if out.A < 0 || out.B < 0 {
+		return Presses{}, ErrNegative
+	}
+	if r.Limit > 0 && (out.A > r.Limit || out.B > r.Limit) {
+		return Presses{}, ErrTooManyPresses
+	}
+	return out, nil
+}
+
+// cross returns x1*y2 - y1*x2.
+func cross(x1, y1, x2, y2 int) (int, error) {
+	p1, err := checked.Mul(x1, y2)
+	if err != nil {
+		return 0, err
+	}
+	p2, err := checked.Mul(y1, x2)
+	if err != nil {
+		return 0, err
+	}
+	return checked.Sub(p1, p2)
+}
+
+// Cost returns the number of tokens needed for the presses.
+func (r Rules) Cost(p Presses) (int, error) {
+	cost, err := checked.Mul(p.A, r.CostA)
+	if err != nil {
+		return 0, err
+	}
+	b, err := checked.Mul(p.B, r.CostB)
+	if err != nil {
+		return 0, err
+	}
+	return checked.Add(cost, b)
+}
+
+// findCost returns the number of tokens needed to win the prize, or 0 if it
+// can't be won. It returns checked.ErrOverflow if any intermediate value
+// doesn't fit in an int.
+func (m *Machine) findCost(r Rules) (int, error) {
+	presses, err := m.solve(r)
+	if errors.Is(err, ErrUnwinnable) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	return r.Cost(presses)
+}
+
+// floorDiv returns n/d rounded down.
+func floorDiv(n, d *big.Int) *big.Int {
+	if d.Sign() < 0 {
+		n, d = new(big.Int).Neg(n), new(big.Int).Neg(d)
+	}
+	// Euclidean division rounds down when d is positive.
+	return new(big.Int).Div(n, d)
+}
+
+// ceilDiv returns n/d rounded up.
+func ceilDiv(n, d *big.Int) *big.Int {
+	q := floorDiv(new(big.Int).Neg(n), d)
+	return q.Neg(q)
+}
+
+// interval is a range of integers. A nil bound means there is no limit in
+// that direction.
+type interval struct {
+	lo, hi *big.Int
+}
+
+// require narrows the interval to the t for which c + t*k >= 0. It returns
+// false if the interval is now empty.
+func (in *interval) require(c, k *big.Int) bool {
+	switch k.Sign() {
+	case 0:
+		return c.Sign() >= 0
+	case 1:
+		if lo := ceilDiv(new(big.Int).Neg(c), k); in.lo == nil || lo.Cmp(in.lo) > 0 {
+			in.lo = lo
+		}
+	case -1:
+		if hi := floorDiv(new(big.Int).Neg(c), k); in.hi == nil || hi.Cmp(in.hi) < 0 {
+			in.hi = hi
+		}
+	}
+	return in.lo == nil || in.hi == nil || in.lo.Cmp(in.hi) <= 0
+}
+
+// solveBig is the same as solve, but uses arbitrary precision so that it
+// can't overflow.
+func (m *Machine) solveBig(r Rules) (a, b *big.Int, err error) {
+	offset := big.NewInt(int64(r.Offset))
+	px := new(big.Int).Add(big.NewInt(int64(m.p.x)), offset)
+	py := new(big.Int).Add(big.NewInt(int64(m.p.y)), offset)
+	ax, ay := big.NewInt(int64(m.a.dx)), big.NewInt(int64(m.a.dy))
+	bx, by := big.NewInt(int64(m.b.dx)), big.NewInt(int64(m.b.dy))
+	crossBig := func(x1, y1, x2, y2 *big.Int) *big.Int {
+		n := new(big.Int).Mul(x1, y2)
+		return n.Sub(n, new(big.Int).Mul(y1, x2))
+	}
+
+	if d := crossBig(ax, ay, bx, by); d.Sign() != 0 {
+		// Solve the system of equations that will give the answer
+		a, rem := new(big.Int).QuoRem(crossBig(px, py, bx, by), d, new(big.Int))
+		if rem.Sign() != 0 {
+			return nil, nil, ErrNotWhole
+		}
+		b, rem = new(big.Int).QuoRem(crossBig(ax, ay, px, py), d, rem)
+		if rem.Sign() != 0 {
+			return nil, nil, ErrNotWhole
+		}
+		if a.Sign() < 0 || b.Sign() < 0 {
+			return nil, nil, ErrNegative
+		}
+		if l := big.NewInt(int64(r.Limit)); r.Limit > 0 && (a.Cmp(l) > 0 || b.Cmp(l) > 0) {
+			return nil, nil, ErrTooManyPresses
+		}
+		return a, b, nil
+	}
+
+	// The buttons are in line with each other. The prize must be on the
+	// same line, and then only one coordinate needs solving:
+	//   a*u + b*v = w
+	if crossBig(px, py, ax, ay).Sign() != 0 || crossBig(px, py, bx, by).Sign() != 0 {
+		return nil, nil, ErrNotInLine
+	}
+	u, v, w := ax, bx, px
+	if u.Sign() == 0 && v.Sign() == 0 {
+		u, v, w = ay, by, py
+	}
+	if u.Sign() == 0 && v.Sign() == 0 {
+		// Neither button moves the claw.
+		if px.Sign() != 0 || py.Sign() != 0 {
+			return nil, nil, ErrNotInLine
+		}
+		return new(big.Int), new(big.Int), nil
+	}
+
+	// Every solution is a = a0 + t*sv, b = b0 - t*su for some integer t.
+	x, y := new(big.Int), new(big.Int)
+	g := new(big.Int).GCD(x, y, u, v)
+	k, rem := new(big.Int).QuoRem(w, g, new(big.Int))
+	if rem.Sign() != 0 {
+		return nil, nil, ErrNotWhole
+	}
+	a0, b0 := x.Mul(x, k), y.Mul(y, k)
+	su, sv := new(big.Int).Quo(u, g), new(big.Int).Quo(v, g)
+	negSu := new(big.Int).Neg(su)
 
-	a2 := m.a.dy * m.b.dx
-	p2 := p.y * m.b.dx
+	// Find the values of t that don't need negative presses, or too many.
+	var t interval
+	if !t.require(a0, sv) || !t.require(b0, negSu) {
+		return nil, nil, ErrNegative
+	}
+	if r.Limit > 0 {
+		l := big.NewInt(int64(r.Limit))
+		if !t.require(new(big.Int).Sub(l, a0), new(big.Int).Neg(sv)) ||
+			!t.require(new(big.Int).Sub(l, b0), su) {
+			return nil, nil, ErrTooManyPresses
+		}
+	}
 
-	n := p1 - p2
-	d := a1 - a2
-	if n%d != 0 {
-		return 0
+	// The cost changes by the same amount for each step of t, so the
+	// cheapest solution is at one end of the interval. The cost can't go
+	// below zero, so there is always a bound at the cheap end. If the cost
+	// doesn't change, the interval is bounded at both ends, and the end
+	// with the fewest presses wins.
+	slope := new(big.Int).Mul(sv, big.NewInt(int64(r.CostA)))
+	slope.Sub(slope, new(big.Int).Mul(su, big.NewInt(int64(r.CostB))))
+	if slope.Sign() == 0 {
+		slope.Sub(sv, su)
 	}
-	a := n / d
+	best := t.lo
+	if slope.Sign() < 0 {
+		best = t.hi
+	}
+	a = new(big.Int).Add(a0, new(big.Int).Mul(best, sv))
+	b = new(big.Int).Sub(b0, new(big.Int).Mul(best, su))
+	return a, b, nil
+}
 
-	n = p.x - a*m.a.dx
-	d = m.b.dx
-	if n%d != 0 {
-		return 0
+// findCostBig is the same as findCost, but uses arbitrary precision so that
+// it can't overflow.
+func (m *Machine) findCostBig(r Rules) *big.Int {
+	a, b, err := m.solveBig(r)
+	if err != nil {
+		return new(big.Int)
 	}
-	b := n / d
-	return a*3 + b
+	return r.CostBig(a, b)
+}
+
+// CostBig returns the number of tokens needed to press A a times and B b
+// times.
+func (r Rules) CostBig(a, b *big.Int) *big.Int {
+	cost := new(big.Int).Mul(a, big.NewInt(int64(r.CostA)))
+	return cost.Add(cost, new(big.Int).Mul(b, big.NewInt(int64(r.CostB))))
 }
 
 func parseInput(filename string) ([]*Machine, error) {
@@ -59,30 +348,30 @@ func parseInput(filename string) ([]*Machine, error) {
 	var machines []*Machine
 	re := regexp.MustCompile(`Button A: X\+(\d+), Y\+(\d+)\nButton B: X\+(\d+), Y\+(\d+)\nPrize: X=(\d+), Y=(\d+)`)
 	ms := re.FindAllStringSubmatch(string(input), -1)
-	for _, m := range ms {
+	for i, m := range ms {
 		ax, err := strconv.Atoi(m[1])
 		if err != nil {
-			return nil, nil
+			return nil, fmt.Errorf("machine %d: %w", i+1, err)
 		}
 		ay, err := strconv.Atoi(m[2])
 		if err != nil {
-			return nil, nil
+			return nil, fmt.Errorf("machine %d: %w", i+1, err)
 		}
 		bx, err := strconv.Atoi(m[3])
 		if err != nil {
-			return nil, nil
+			return nil, fmt.Errorf("machine %d: %w", i+1, err)
 		}
 		by, err := strconv.Atoi(m[4])
 		if err != nil {
-			return nil, nil
+			return nil, fmt.Errorf("machine %d: %w", i+1, err)
 		}
 		px, err := strconv.Atoi(m[5])
 		if err != nil {
-			return nil, nil
+			return nil, fmt.Errorf("machine %d: %w", i+1, err)
 		}
 		py, err := strconv.Atoi(m[6])
 		if err != nil {
-			return nil, nil
+			return nil, fmt.Errorf("machine %d: %w", i+1, err)
 		}
 		machines = append(machines, &Machine{
 			a: Button{ax, ay},
@@ -93,31 +382,104 @@ func parseInput(filename string) ([]*Machine, error) {
 	return machines, nil
 }
 
-func Part1(machines []*Machine) int {
+// Total returns the total cost of winning every prize that can be won.
+func Total(machines []*Machine, r Rules) (int, error) {
 	var total int
 	for _, m := range machines {
-		cost := m.findCost(m.p)
-		total += cost
+		cost, err := m.findCost(r)
+		if err != nil {
+			return 0, err
+		}
+		if total, err = checked.Add(total, cost); err != nil {
+			return 0, err
+		}
 	}
-	return total
+	return total, nil
 }
 
-func Part2(machines []*Machine) int {
-	var total int
+func Part1(machines []*Machine) (int, error) {
+	return Total(machines, Part1Rules)
+}
+
+func Part2(machines []*Machine) (int, error) {
+	return Total(machines, Part2Rules)
+}
+
+// TotalBig is the same as Total, but uses arbitrary precision arithmetic.
+func TotalBig(machines []*Machine, r Rules) *big.Int {
+	total := new(big.Int)
 	for _, m := range machines {
-		p := Prize{
-			m.p.x + 10000000000000,
-			m.p.y + 10000000000000,
-		}
-		cost := m.findCost(p)
-		total += cost
+		total.Add(total, m.findCostBig(r))
 	}
 	return total
 }
 
+// Report describes how to win each prize, or why it can't be won. It uses
+// arbitrary precision arithmetic, so it never overflows.
+func Report(w io.Writer, machines []*Machine, r Rules) error {
+	var s strings.Builder
+	for i, m := range machines {
+		fmt.Fprintf(&s, "Machine %d: ", i+1)
+		a, b, err := m.solveBig(r)
+		if err != nil {
+			fmt.Fprintf(&s, "%v\n", err)
+			continue
+		}
+		fmt.Fprintf(&s, "A %s times, B %s times, %s tokens\n", a, b, r.CostBig(a, b))
+	}
+	_, err := io.WriteString(w, s.String())
+	return err
+}
+
+// printTotal prints the total cost, switching to big arithmetic if asked
+// to or if the calculation overflows.
+func printTotal(name string, machines []*Machine, r Rules, useBig bool) {
+	if !useBig {
+		total, err := Total(machines, r)
+		if err == nil {
+			fmt.Printf("%s: %d\n", name, total)
+			return
+		}
+		if !errors.Is(err, checked.ErrOverflow) {
+			log.Fatal(err)
+		}
+		log.Printf("%s: %v, switching to big arithmetic", name, err)
+	}
+	fmt.Printf("%s: %s\n", name, TotalBig(machines, r))
+}
+
 func main() {
-	_, err := parseInput(os.Args[1])
+	useBig := flag.Bool("big", false, "use arbitrary precision arithmetic")
+	report := flag.Bool("report", false, "print how to win each prize, or why it can't be won")
+	part1, part2 := Part1Rules, Part2Rules
+	flag.IntVar(&part1.CostA, "cost-a", part1.CostA, "the number of tokens it costs to press button A")
+	flag.IntVar(&part1.CostB, "cost-b", part1.CostB, "the number of tokens it costs to press button B")
+	flag.IntVar(&part1.Limit, "limit", part1.Limit, "the most times each button can be pressed in Part 1, or 0 for no limit")
+	flag.IntVar(&part2.Offset, "offset", part2.Offset, "the distance the prizes are moved in Part 2")
+	flag.Parse()
+	part2.CostA, part2.CostB = part1.CostA, part1.CostB
+	for _, r := range []Rules{part1, part2} {
+		if err := r.Validate(); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	machines, err := parseInput(flag.Arg(0))
 	if err != nil {
 		log.Fatal(err)
 	}
+	for _, part := range []struct {
+		name  string
+		rules Rules
+	}{
+		{"Part 1", part1},
+		{"Part 2", part2},
+	} {
+		if *report {
+			if err := Report(os.Stdout, machines, part.rules); err != nil {
+				log.Fatal(err)
+			}
+		}
+		printTotal(part.name, machines, part.rules, *useBig)
+	}
 }
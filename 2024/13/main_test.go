@@ -1,8 +1,15 @@
 package main
 
 import (
+	"math"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
 
+	"github.com/TonyRippy/advent-of-code/2024/checked"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -18,7 +25,10 @@ func TestPart1(t *testing.T) {
 		t.Run(tc.filename, func(t *testing.T) {
 			ms, err := parseInput(tc.filename)
 			require.NoError(t, err)
-			assert.Equal(t, tc.expected, Part1(ms))
+			total, err := Part1(ms)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, total)
+			assert.Equal(t, big.NewInt(int64(tc.expected)), TotalBig(ms, Part1Rules))
 		})
 	}
 }
@@ -33,7 +43,189 @@ func TestPart2(t *testing.T) {
 		t.Run(tc.filename, func(t *testing.T) {
 			ms, err := parseInput(tc.filename)
 			require.NoError(t, err)
-			assert.Equal(t, tc.expected, Part2(ms))
+			total, err := Part2(ms)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, total)
+			assert.Equal(t, big.NewInt(int64(tc.expected)), TotalBig(ms, Part2Rules))
+		})
+	}
+}
+
+// noLimit is the Part 1 rules, without the limit on presses.
+var noLimit = Rules{CostA: 3, CostB: 1}
+
+func TestOverflow(t *testing.T) {
+	m := &Machine{
+		a: Button{94, 34},
+		b: Button{22, 67},
+		p: Prize{math.MaxInt - 1000, math.MaxInt - 1000},
+	}
+	_, err := m.findCost(noLimit)
+	assert.ErrorIs(t, err, checked.ErrOverflow)
+	_, err = Part2([]*Machine{m})
+	assert.ErrorIs(t, err, checked.ErrOverflow)
+}
+
+func FuzzFindCost(f *testing.F) {
+	f.Add(94, 34, 22, 67, 8400, 5400)
+	f.Add(26, 66, 67, 21, 10000000012748, 10000000012176)
+	f.Add(94, 34, 22, 67, math.MaxInt-1000, math.MaxInt-1000)
+	f.Fuzz(func(t *testing.T, ax, ay, bx, by, px, py int) {
+		m := &Machine{a: Button{ax, ay}, b: Button{bx, by}, p: Prize{px, py}}
+		want := m.findCostBig(noLimit)
+		got, err := m.findCost(noLimit)
+		if err != nil {
+			assert.ErrorIs(t, err, checked.ErrOverflow)
+			return
+		}
+		assert.Equal(t, want.String(), strconv.Itoa(got))
+	})
+}
+
+// bruteForce tries every combination of presses allowed by the rules, which
+// must have a limit.
+func bruteForce(m *Machine, r Rules) (Presses, bool) {
+	var best Presses
+	found := false
+	for a := 0; a <= r.Limit; a++ {
+		for b := 0; b <= r.Limit; b++ {
+			if a*m.a.dx+b*m.b.dx != m.p.x+r.Offset || a*m.a.dy+b*m.b.dy != m.p.y+r.Offset {
+				continue
+			}
+			if !found || a*r.CostA+b*r.CostB < best.A*r.CostA+best.B*r.CostB {
+				best, found = Presses{a, b}, true
+			}
+		}
+	}
+	return best, found
+}
+
+func TestSolve(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		m     Machine
+		limit int
+		want  Presses
+		err   error
+	}{
+		{"puzzle", Machine{Button{94, 34}, Button{22, 67}, Prize{8400, 5400}}, 100, Presses{80, 40}, nil},
+		{"fraction", Machine{Button{26, 66}, Button{67, 21}, Prize{12748, 12176}}, 100, Presses{}, ErrNotWhole},
+		{"negative", Machine{Button{1, 0}, Button{0, 1}, Prize{-1, 5}}, 0, Presses{}, ErrNegative},
+		{"too many", Machine{Button{1, 0}, Button{0, 1}, Prize{101, 5}}, 100, Presses{}, ErrTooManyPresses},
+		{"no B", Machine{Button{1, 1}, Button{0, 0}, Prize{7, 7}}, 0, Presses{7, 0}, nil},
+		// A moves three times as far as B, for three times the price.
+		{"same price", Machine{Button{3, 3}, Button{1, 1}, Prize{7, 7}}, 0, Presses{2, 1}, nil},
+		// A moves four times as far, so is cheaper.
+		{"cheap A", Machine{Button{4, 8}, Button{1, 2}, Prize{10, 20}}, 0, Presses{2, 2}, nil},
+		{"cheap B", Machine{Button{2, 2}, Button{3, 3}, Prize{12, 12}}, 0, Presses{0, 4}, nil},
+		{"cheap B over limit", Machine{Button{2, 2}, Button{3, 3}, Prize{400, 400}}, 100, Presses{50, 100}, nil},
+		{"not in line", Machine{Button{2, 2}, Button{3, 3}, Prize{12, 13}}, 0, Presses{}, ErrNotInLine},
+		{"gcd", Machine{Button{4, 4}, Button{6, 6}, Prize{7, 7}}, 0, Presses{}, ErrNotWhole},
+		{"vertical", Machine{Button{0, 5}, Button{0, 2}, Prize{0, 9}}, 0, Presses{1, 2}, nil},
+		{"still", Machine{Button{0, 0}, Button{0, 0}, Prize{0, 0}}, 0, Presses{0, 0}, nil},
+		{"stuck", Machine{Button{0, 0}, Button{0, 0}, Prize{1, 0}}, 0, Presses{}, ErrNotInLine},
+		{"opposite", Machine{Button{2, 2}, Button{-1, -1}, Prize{3, 3}}, 0, Presses{2, 1}, nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := tc.m.solve(Rules{CostA: 3, CostB: 1, Limit: tc.limit})
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+				assert.ErrorIs(t, err, ErrUnwinnable)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
 		})
 	}
 }
+
+func FuzzSolve(f *testing.F) {
+	f.Add(int8(94), int8(34), int8(22), int8(67), int16(8400), int16(5400))
+	f.Add(int8(3), int8(3), int8(1), int8(1), int16(7), int16(7))
+	f.Add(int8(2), int8(4), int8(3), int8(6), int16(100), int16(200))
+	f.Add(int8(0), int8(0), int8(0), int8(5), int16(0), int16(50))
+	f.Add(int8(2), int8(2), int8(-1), int8(-1), int16(3), int16(3))
+	f.Fuzz(func(t *testing.T, ax, ay, bx, by int8, px, py int16) {
+		m := &Machine{
+			a: Button{int(ax), int(ay)},
+			b: Button{int(bx), int(by)},
+			p: Prize{int(px), int(py)},
+		}
+		want, ok := bruteForce(m, Part1Rules)
+		got, err := m.solve(Part1Rules)
+		if !ok {
+			assert.ErrorIs(t, err, ErrUnwinnable)
+			return
+		}
+		require.NoError(t, err)
+		wantCost, _ := Part1Rules.Cost(want)
+		gotCost, _ := Part1Rules.Cost(got)
+		assert.Equal(t, wantCost, gotCost)
+		assert.Equal(t, m.p.x, got.A*m.a.dx+got.B*m.b.dx)
+		assert.Equal(t, m.p.y, got.A*m.a.dy+got.B*m.b.dy)
+
+		// Without the limit there may be a cheaper solution, but not if
+		// the cheapest one is inside the limit.
+		got, err = m.solve(noLimit)
+		require.NoError(t, err)
+		gotCost, _ = noLimit.Cost(got)
+		assert.LessOrEqual(t, gotCost, wantCost)
+		if got.A <= Part1Rules.Limit && got.B <= Part1Rules.Limit {
+			assert.Equal(t, wantCost, gotCost)
+		}
+	})
+}
+
+func TestReport(t *testing.T) {
+	ms, err := parseInput("test.txt")
+	require.NoError(t, err)
+	var b strings.Builder
+	require.NoError(t, Report(&b, ms, Part1Rules))
+	assert.Equal(t, `Machine 1: A 80 times, B 40 times, 280 tokens
+Machine 2: prize can't be won: no whole number of presses reaches it
+Machine 3: A 38 times, B 86 times, 200 tokens
+Machine 4: prize can't be won: no whole number of presses reaches it
+`, b.String())
+
+	// Making B expensive doesn't change anything, as each machine only
+	// has one way to win.
+	r := Part1Rules
+	r.CostB = 10
+	b.Reset()
+	require.NoError(t, Report(&b, ms[:1], r))
+	assert.Equal(t, "Machine 1: A 80 times, B 40 times, 640 tokens\n", b.String())
+
+	// It can when the buttons are in line.
+	m := &Machine{Button{2, 2}, Button{1, 1}, Prize{10, 10}}
+	b.Reset()
+	require.NoError(t, Report(&b, []*Machine{m}, r))
+	assert.Equal(t, "Machine 1: A 5 times, B 0 times, 15 tokens\n", b.String())
+	b.Reset()
+	r.Offset = 1
+	require.NoError(t, Report(&b, []*Machine{m}, r))
+	assert.Equal(t, "Machine 1: A 5 times, B 1 times, 25 tokens\n", b.String())
+}
+
+func TestParseErrors(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "input.txt")
+	input := "Button A: X+94, Y+34\nButton B: X+22, Y+67\nPrize: X=8400, Y=5400\n\n" +
+		"Button A: X+26, Y+66\nButton B: X+67, Y+21\nPrize: X=99999999999999999999, Y=12176\n"
+	require.NoError(t, os.WriteFile(filename, []byte(input), 0o644))
+	_, err := parseInput(filename)
+	assert.ErrorIs(t, err, strconv.ErrRange)
+	assert.ErrorContains(t, err, "machine 2")
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, Part1Rules.Validate())
+	assert.NoError(t, Part2Rules.Validate())
+	for _, r := range []Rules{
+		{CostA: 0, CostB: 1},
+		{CostA: -1, CostB: 1},
+		{CostA: 3, CostB: -1},
+		{CostA: 3, CostB: 1, Limit: -1},
+		{CostA: 3, CostB: 1, Offset: -1},
+	} {
+		assert.Error(t, r.Validate(), "%+v", r)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"os"
+)
+
+var (
+	background = color.Gray{0}
+	robotColor = color.Gray{255}
+	// gapColor separates the seconds on a contact sheet.
+	gapColor = color.Gray{96}
+)
+
+// drawRobots draws the robots onto img, with the top left of the space at
+// (x0, y0). Each robot is a scale×scale square.
+func drawRobots(img *image.Gray, points []Point, x0, y0, scale int) {
+	for _, p := range points {
+		for dy := range scale {
+			for dx := range scale {
+				img.SetGray(x0+p.X*scale+dx, y0+p.Y*scale+dy, robotColor)
+			}
+		}
+	}
+}
+
+func fill(img *image.Gray, c color.Gray) {
+	for i := range img.Pix {
+		img.Pix[i] = c.Y
+	}
+}
+
+// writePNG draws the robots in a space w wide and h high as a PNG image.
+func writePNG(out io.Writer, points []Point, w, h, scale int) error {
+	img := image.NewGray(image.Rect(0, 0, w*scale, h*scale))
+	fill(img, background)
+	drawRobots(img, points, 0, 0, scale)
+	return png.Encode(out, img)
+}
+
+// writeContactSheet draws the robots at each second from first to last as a
+// grid of images in a single PNG, with columns seconds to a row. Reading left
+// to right and top to bottom, the image in row r and column c is the second
+// first + r*columns + c.
+func writeContactSheet(out io.Writer, robots []*Robot, first, last, w, h, columns, scale int) error {
+	n := last - first + 1
+	columns = max(1, min(columns, n))
+	rows := (n + columns - 1) / columns
+	const gap = 1
+	img := image.NewGray(image.Rect(0, 0, columns*(w*scale+gap)+gap, rows*(h*scale+gap)+gap))
+	fill(img, gapColor)
+	for i := range n {
+		x0 := gap + (i%columns)*(w*scale+gap)
+		y0 := gap + (i/columns)*(h*scale+gap)
+		for y := range h * scale {
+			for x := range w * scale {
+				img.SetGray(x0+x, y0+y, background)
+			}
+		}
+		drawRobots(img, positions(robots, first+i, w, h), x0, y0, scale)
+	}
+	return png.Encode(out, img)
+}
+
+// writePNGFile creates the named file and writes an image to it.
+func writePNGFile(name string, write func(io.Writer) error) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
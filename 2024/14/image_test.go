@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWritePNG(t *testing.T) {
+	var b bytes.Buffer
+	require.NoError(t, writePNG(&b, []Point{{0, 0}, {10, 6}}, 11, 7, 2))
+	img, err := png.Decode(&b)
+	require.NoError(t, err)
+	assert.Equal(t, 22, img.Bounds().Dx())
+	assert.Equal(t, 14, img.Bounds().Dy())
+	gray := func(x, y int) uint32 {
+		v, _, _, _ := img.At(x, y).RGBA()
+		return v >> 8
+	}
+	assert.Equal(t, uint32(255), gray(1, 1))
+	assert.Equal(t, uint32(255), gray(21, 13))
+	assert.Equal(t, uint32(0), gray(2, 2))
+}
+
+func TestWriteContactSheet(t *testing.T) {
+	robots, err := parseInput("test.txt")
+	require.NoError(t, err)
+	var b bytes.Buffer
+	// Seven seconds, three to a row, is three rows.
+	require.NoError(t, writeContactSheet(&b, robots, 5, 11, 11, 7, 3, 1))
+	img, err := png.Decode(&b)
+	require.NoError(t, err)
+	assert.Equal(t, 3*12+1, img.Bounds().Dx())
+	assert.Equal(t, 3*8+1, img.Bounds().Dy())
+
+	// The last image, the 11th second, is in the first column of the
+	// last row.
+	for _, p := range positions(robots, 11, 11, 7) {
+		v, _, _, _ := img.At(1+p.X, 1+2*8+p.Y).RGBA()
+		assert.Equal(t, uint32(255), v>>8)
+	}
+}
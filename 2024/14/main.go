@@ -1,11 +1,15 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"log"
+	"math"
 	"os"
+	"path/filepath"
 	"regexp"
-	"slices"
 	"strconv"
 )
 
@@ -45,49 +49,42 @@ func parseInput(filename string) ([]*Robot, error) {
 	return robots, nil
 }
 
-func step(robots []*Robot, w, h int) {
-	for _, r := range robots {
-		r.px += r.vx
-		for r.px < 0 {
-			r.px += w
-		}
-		r.px %= w
-		r.py += r.vy
-		for r.py < 0 {
-			r.py += h
-		}
-		r.py %= h
+type Point struct {
+	X, Y int
+}
+
+// mod returns a modulo m, in the range [0, m).
+func mod(a, m int) int {
+	a %= m
+	if a < 0 {
+		a += m
 	}
+	return a
 }
 
-func Part1(robots []*Robot, seconds, w, h int) int {
-	for range seconds {
-		step(robots, w, h)
-	}
-	if w%2 == 0 || h%2 == 0 {
-		panic("width/height must be odd")
-	}
-	// count robots in quadrants
-	mx := w / 2
-	my := h / 2
-	var quadrants [4]int
-	for _, r := range robots {
-		if r.px < mx {
-			if r.py < my {
-				quadrants[0]++
-			} else if r.py > my {
-				quadrants[2]++
-			}
-		} else if r.px > mx {
-			if r.py < my {
-				quadrants[1]++
-			} else if r.py > my {
-				quadrants[3]++
-			}
-		}
+// At returns where the robot is after t seconds, in a space w wide and h
+// high. Robots wrap around the edges, so each coordinate repeats after w or h
+// seconds, and t is reduced first so that large times don't overflow.
+func (r *Robot) At(t, w, h int) Point {
+	return Point{
+		X: mod(r.px+r.vx*mod(t, w), w),
+		Y: mod(r.py+r.vy*mod(t, h), h),
+	}
+}
+
+// positions returns where every robot is after t seconds.
+func positions(robots []*Robot, t, w, h int) []Point {
+	out := make([]Point, len(robots))
+	for i, r := range robots {
+		out[i] = r.At(t, w, h)
 	}
+	return out
+}
+
+func Part1(robots []*Robot, seconds, w, h int) int {
+	q := Quadrants(positions(robots, seconds, w, h), w, h)
 	// Calculate safety factor
-	return quadrants[0] * quadrants[1] * quadrants[2] * quadrants[3]
+	return q[0] * q[1] * q[2] * q[3]
 }
 
 func makeMap(w, h int) [][]byte {
@@ -98,7 +95,7 @@ func makeMap(w, h int) [][]byte {
 	return m
 }
 
-func setMap(m [][]byte, robots []*Robot) {
+func setMap(m [][]byte, points []Point) {
 	// initialize the map
 	for _, row := range m {
 		for i, _ := range row {
@@ -106,8 +103,8 @@ func setMap(m [][]byte, robots []*Robot) {
 		}
 	}
 	// mark the robots
-	for _, r := range robots {
-		m[r.py][r.px] = '#'
+	for _, p := range points {
+		m[p.Y][p.X] = '#'
 	}
 }
 
@@ -118,69 +115,138 @@ func printMap(s int, m [][]byte) {
 	fmt.Printf("============================================ %d\n", s)
 }
 
-func isSymmetric(m [][]byte) bool {
-	// check to see if the map is symmetric accross the x-axis
-	mx := len(m[0]) / 2
-	for _, s := range m {
-		h1 := s[:mx]
-		h2 := s[mx+1:]
-		slices.Reverse(h2)
-		if !slices.Equal(h1, h2) {
-			return false
-		}
+// variance returns the variance of the values, times len(values)². Scaling
+// it up keeps it exact, and it is only used to compare seconds with the same
+// number of robots.
+func variance(values []int) int {
+	var sum, squares int
+	for _, v := range values {
+		sum += v
+		squares += v * v
 	}
-	return true
+	return len(values)*squares - sum*sum
 }
- func isDenseX(m [][]byte, threshold int) bool {
-	for _, row := range m {
-		count := 0
-		for _, c := range row {
-			if c == '#' {
-				count++
-			}
+
+// gcd returns the greatest common divisor of a and b, along with x and y
+// such that a*x + b*y = gcd(a, b).
+func gcd(a, b int) (g, x, y int) {
+	if b == 0 {
+		return a, 1, 0
+	}
+	g, x, y = gcd(b, a%b)
+	return g, y, x - (a/b)*y
+}
+
+// crt returns the smallest t >= 0 where t%m == a and t%n == b, and the period
+// after which the solutions repeat. It returns false if there is no such t.
+func crt(a, m, b, n int) (t, period int, ok bool) {
+	g, x, _ := gcd(m, n)
+	if (b-a)%g != 0 {
+		return 0, 0, false
+	}
+	period = m / g * n
+	// t = a + m*k, where m*k ≡ b-a (mod n).
+	k := (b - a) / g * x % (n / g)
+	t = (a + m*k) % period
+	if t < 0 {
+		t += period
+	}
+	return t, period, true
+}
+
+// Part2 finds the first second at which the robots form a picture of a
+// Christmas tree. The robots are mostly scattered at random, but the ones in
+// the picture are bunched together, so the picture appears at the second when
+// the robots are least spread out. Each robot's x position repeats every w
+// seconds, and its y position every h seconds. So the x positions are least
+// spread out at some second tx < w, the y positions at some ty < h, and both
+// at once at the second that matches both, which the Chinese remainder
+// theorem finds.
+func Part2(robots []*Robot, w, h int) (int, error) {
+	if len(robots) == 0 {
+		return 0, errors.New("no robots")
+	}
+	xs := make([]int, len(robots))
+	ys := make([]int, len(robots))
+	tx, ty := 0, 0
+	bestX, bestY := math.MaxInt, math.MaxInt
+	for t := range max(w, h) {
+		for i, r := range robots {
+			p := r.At(t, w, h)
+			xs[i], ys[i] = p.X, p.Y
 		}
-		if count > threshold {
-			return true
+		if v := variance(xs); t < w && v < bestX {
+			tx, bestX = t, v
 		}
+		if v := variance(ys); t < h && v < bestY {
+			ty, bestY = t, v
+		}
+	}
+	t, _, ok := crt(tx, w, ty, h)
+	if !ok {
+		return 0, fmt.Errorf("no second has x%%%d=%d and y%%%d=%d", w, tx, h, ty)
 	}
-	return false
+	return t, nil
 }
 
-func isDenseY(m [][]byte, threshold int) bool {
-	for i := range m[0] {
-		count := 0
-		for _, row := range m {
-			if row[i] == '#' {
-				count++
+func main() {
+	w := flag.Int("width", 101, "the width of the space the robots are in")
+	h := flag.Int("height", 103, "the height of the space the robots are in")
+	show := flag.Bool("show", false, "print the robots at the second found in Part 2")
+	pngDir := flag.String("png", "", "write a PNG image of each second from -from to -to into this directory")
+	sheet := flag.String("sheet", "", "write a PNG contact sheet of the seconds from -from to -to to this file")
+	from := flag.Int("from", 0, "the first second to draw")
+	to := flag.Int("to", 0, "the last second to draw, if after -from")
+	scale := flag.Int("scale", 2, "the size of each robot in PNG images, in pixels")
+	columns := flag.Int("columns", 10, "the number of seconds in each row of the contact sheet")
+	rank := flag.Int("rank", 0, "print this many of the top seconds by each statistic, from -from to -to, or every second before the robots repeat")
+	flag.Parse()
+
+	robots, err := parseInput(flag.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	last := max(*from, *to)
+	if *pngDir != "" {
+		for t := *from; t <= last; t++ {
+			name := filepath.Join(*pngDir, fmt.Sprintf("%05d.png", t))
+			if err := writePNGFile(name, func(out io.Writer) error {
+				return writePNG(out, positions(robots, t, *w, *h), *w, *h, *scale)
+			}); err != nil {
+				log.Fatal(err)
 			}
 		}
-		if count > threshold {
-			return true
+	}
+	if *sheet != "" {
+		if err := writePNGFile(*sheet, func(out io.Writer) error {
+			return writeContactSheet(out, robots, *from, last, *w, *h, *columns, *scale)
+		}); err != nil {
+			log.Fatal(err)
 		}
 	}
-	return false
-}
-
-// Printf problem solving....
-func Part2(robots []*Robot)  {
-	m := makeMap(101, 103)
-	var s int
-	for {
-		fmt.Printf("%d\r", s)
-		setMap(m, robots)
-		if isDenseX(m, 20) || isDenseY(m, 30) {
-			printMap(s, m)
-			fmt.Scanln()
+	if *rank > 0 {
+		first, end := *from, last+1
+		if *to <= *from {
+			first, end = 0, (*w)*(*h)
+		}
+		var stats []FrameStats
+		for t := first; t < end; t++ {
+			stats = append(stats, Stats(robots, t, *w, *h))
+		}
+		if err := writeRankings(os.Stdout, stats, *rank); err != nil {
+			log.Fatal(err)
 		}
-		step(robots, 101, 103)
-		s++
 	}
-}
 
-func main() {
-	robots, err := parseInput("input.txt")
+	fmt.Printf("Part 1: %d\n", Part1(robots, 100, *w, *h))
+	t, err := Part2(robots, *w, *h)
 	if err != nil {
 		log.Fatal(err)
 	}
-	Part2(robots)
+	if *show {
+		m := makeMap(*w, *h)
+		setMap(m, positions(robots, t, *w, *h))
+		printMap(t, m)
+	}
+	fmt.Printf("Part 2: %d\n", t)
 }
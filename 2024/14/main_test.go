@@ -1,6 +1,7 @@
 package main
 
 import (
+	"math"
 	"slices"
 	"testing"
 
@@ -41,3 +42,64 @@ func TestPart1(t *testing.T) {
 		})
 	}
 }
+
+func TestPart2(t *testing.T) {
+	robots, err := parseInput("input.txt")
+	require.NoError(t, err)
+	got, err := Part2(robots, 101, 103)
+	require.NoError(t, err)
+	assert.Equal(t, 8179, got)
+
+	// The robots themselves don't move.
+	again, err := Part2(robots, 101, 103)
+	require.NoError(t, err)
+	assert.Equal(t, got, again)
+
+	_, err = Part2(nil, 101, 103)
+	assert.Error(t, err)
+}
+
+func TestCRT(t *testing.T) {
+	for _, tc := range []struct {
+		a, m, b, n   int
+		want, period int
+		ok           bool
+	}{
+		{0, 3, 0, 5, 0, 15, true},
+		{2, 3, 3, 5, 8, 15, true},
+		{1, 101, 2, 103, 5152, 10403, true},
+		// The periods don't have to be coprime.
+		{1, 4, 3, 6, 9, 12, true},
+		{0, 4, 1, 6, 0, 0, false},
+	} {
+		got, period, ok := crt(tc.a, tc.m, tc.b, tc.n)
+		assert.Equal(t, tc.ok, ok, "%+v", tc)
+		if tc.ok {
+			assert.Equal(t, tc.want, got, "%+v", tc)
+			assert.Equal(t, tc.period, period, "%+v", tc)
+		}
+	}
+}
+
+func TestAt(t *testing.T) {
+	robots, err := parseInput("input.txt")
+	require.NoError(t, err)
+	// Move each robot one second at a time, and check it ends up where the
+	// closed form says.
+	pos := make([]Point, len(robots))
+	for i, r := range robots {
+		pos[i] = Point{r.px, r.py}
+	}
+	for s := range 300 {
+		for i, r := range robots {
+			require.Equal(t, pos[i], r.At(s, 101, 103), "robot %d at %d", i, s)
+			pos[i] = Point{mod(pos[i].X+r.vx, 101), mod(pos[i].Y+r.vy, 103)}
+		}
+	}
+
+	// Positions repeat, so times too large to multiply by the velocity
+	// still work.
+	r := &Robot{px: 2, py: 4, vx: 2, vy: -3}
+	assert.Equal(t, Point{1, 3}, r.At(5, 11, 7))
+	assert.Equal(t, r.At(math.MaxInt%77, 11, 7), r.At(math.MaxInt, 11, 7))
+}
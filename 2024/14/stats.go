@@ -0,0 +1,183 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// Quadrants counts the robots in each quadrant of a space w wide and h high,
+// in the order top left, top right, bottom left, bottom right. If a side has
+// an odd length, the robots on the middle line aren't in any quadrant. If it
+// has an even length, there is no middle line, and each half gets the same
+// number of rows or columns.
+func Quadrants(points []Point, w, h int) [4]int {
+	var q [4]int
+	for _, p := range points {
+		i, ok := half(p.X, w)
+		if !ok {
+			continue
+		}
+		j, ok := half(p.Y, h)
+		if !ok {
+			continue
+		}
+		q[2*j+i]++
+	}
+	return q
+}
+
+// half returns 0 if v is in the first half of a side of length n, or 1 if it
+// is in the second half. It returns false if v is on the middle line.
+func half(v, n int) (int, bool) {
+	if n%2 == 1 && v == n/2 {
+		return 0, false
+	}
+	if v < n/2 {
+		return 0, true
+	}
+	return 1, true
+}
+
+// FrameStats describes where the robots are at one second.
+type FrameStats struct {
+	Second    int
+	Quadrants [4]int
+	// SafetyFactor is the product of the quadrant counts, as in Part 1.
+	SafetyFactor int
+	// LargestCluster is the number of robots in the largest group of
+	// robots on orthogonally adjacent tiles.
+	LargestCluster int
+	// Symmetry is the fraction of robots whose mirror image, across the
+	// vertical line through the middle of the space, is also a robot.
+	Symmetry float64
+	// Rows and Columns count the robots in each row and column.
+	Rows, Columns []int
+}
+
+// MaxRow returns the number of robots in the busiest row.
+func (s FrameStats) MaxRow() int {
+	return slices.Max(s.Rows)
+}
+
+// MaxColumn returns the number of robots in the busiest column.
+func (s FrameStats) MaxColumn() int {
+	return slices.Max(s.Columns)
+}
+
+// Stats works out the statistics for the robots after t seconds.
+func Stats(robots []*Robot, t, w, h int) FrameStats {
+	points := positions(robots, t, w, h)
+	s := FrameStats{
+		Second:    t,
+		Quadrants: Quadrants(points, w, h),
+		Rows:      make([]int, h),
+		Columns:   make([]int, w),
+	}
+	q := s.Quadrants
+	s.SafetyFactor = q[0] * q[1] * q[2] * q[3]
+
+	// Several robots can share a tile.
+	count := make(map[Point]int, len(points))
+	for _, p := range points {
+		count[p]++
+		s.Rows[p.Y]++
+		s.Columns[p.X]++
+	}
+
+	var mirrored int
+	for _, p := range points {
+		if count[Point{w - 1 - p.X, p.Y}] > 0 {
+			mirrored++
+		}
+	}
+	if len(points) > 0 {
+		s.Symmetry = float64(mirrored) / float64(len(points))
+	}
+
+	seen := make(map[Point]bool, len(count))
+	var stack []Point
+	for start := range count {
+		if seen[start] {
+			continue
+		}
+		seen[start] = true
+		size := 0
+		stack = append(stack[:0], start)
+		for len(stack) > 0 {
+			p := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			size += count[p]
+			for _, n := range []Point{{p.X - 1, p.Y}, {p.X + 1, p.Y}, {p.X, p.Y - 1}, {p.X, p.Y + 1}} {
+				if count[n] > 0 && !seen[n] {
+					seen[n] = true
+					stack = append(stack, n)
+				}
+			}
+		}
+		s.LargestCluster = max(s.LargestCluster, size)
+	}
+	return s
+}
+
+// Metric is a way of ranking seconds. Seconds with a higher score rank
+// first.
+type Metric struct {
+	Name  string
+	Score func(FrameStats) float64
+	// Format shows the value the score is based on.
+	Format func(FrameStats) string
+}
+
+var Metrics = []Metric{
+	{
+		"lowest safety factor",
+		func(s FrameStats) float64 { return -float64(s.SafetyFactor) },
+		func(s FrameStats) string { return fmt.Sprint(s.SafetyFactor) },
+	},
+	{
+		"largest cluster",
+		func(s FrameStats) float64 { return float64(s.LargestCluster) },
+		func(s FrameStats) string { return fmt.Sprint(s.LargestCluster) },
+	},
+	{
+		"symmetry",
+		func(s FrameStats) float64 { return s.Symmetry },
+		func(s FrameStats) string { return fmt.Sprintf("%.3f", s.Symmetry) },
+	},
+	{
+		"busiest row",
+		func(s FrameStats) float64 { return float64(s.MaxRow()) },
+		func(s FrameStats) string { return fmt.Sprint(s.MaxRow()) },
+	},
+	{
+		"busiest column",
+		func(s FrameStats) float64 { return float64(s.MaxColumn()) },
+		func(s FrameStats) string { return fmt.Sprint(s.MaxColumn()) },
+	},
+}
+
+// Rank returns the stats sorted by the metric, best first. Ties are broken
+// by the earlier second.
+func Rank(stats []FrameStats, m Metric) []FrameStats {
+	out := slices.Clone(stats)
+	slices.SortStableFunc(out, func(a, b FrameStats) int {
+		return cmp.Compare(m.Score(b), m.Score(a))
+	})
+	return out
+}
+
+// writeRankings prints the top n seconds for each metric.
+func writeRankings(w io.Writer, stats []FrameStats, n int) error {
+	var s strings.Builder
+	for _, m := range Metrics {
+		fmt.Fprintf(&s, "By %s:\n", m.Name)
+		for _, st := range Rank(stats, m)[:min(n, len(stats))] {
+			fmt.Fprintf(&s, "  %6d: %s\n", st.Second, m.Format(st))
+		}
+	}
+	_, err := io.WriteString(w, s.String())
+	return err
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuadrants(t *testing.T) {
+	// With odd sides, the middle row and column don't count.
+	points := []Point{{0, 0}, {1, 1}, {2, 0}, {4, 0}, {0, 2}, {4, 2}, {4, 1}, {3, 2}}
+	assert.Equal(t, [4]int{1, 1, 1, 2}, Quadrants(points, 5, 3))
+
+	// With even sides, every robot is in a quadrant.
+	points = []Point{{0, 0}, {1, 1}, {2, 0}, {3, 3}, {1, 2}}
+	assert.Equal(t, [4]int{2, 1, 1, 1}, Quadrants(points, 4, 4))
+
+	robots, err := parseInput("test.txt")
+	require.NoError(t, err)
+	assert.NotPanics(t, func() {
+		Part1(robots, 100, 10, 8)
+	})
+}
+
+func TestStats(t *testing.T) {
+	// Two robots share (1, 1), and (3, 1) is only touching (2, 0)
+	// diagonally.
+	//   .#..
+	//   .2.#
+	//   ##..
+	robots := []*Robot{
+		{px: 1, py: 0}, {px: 1, py: 1}, {px: 1, py: 1},
+		{px: 3, py: 1}, {px: 0, py: 2}, {px: 1, py: 2},
+	}
+	s := Stats(robots, 0, 4, 3)
+	assert.Equal(t, 5, s.LargestCluster)
+	assert.Equal(t, []int{1, 3, 2}, s.Rows)
+	assert.Equal(t, []int{1, 4, 0, 1}, s.Columns)
+	assert.Equal(t, 3, s.MaxRow())
+	assert.Equal(t, 4, s.MaxColumn())
+	// None of the robots has a mirror image.
+	assert.Zero(t, s.Symmetry)
+
+	// Now (1, 0) and (2, 0) mirror each other, as do (0, 2) and (3, 2).
+	robots = append(robots, &Robot{px: 3, py: 2}, &Robot{px: 2, py: 0})
+	s = Stats(robots, 0, 4, 3)
+	assert.InDelta(t, 4.0/8.0, s.Symmetry, 1e-9)
+}
+
+func TestRank(t *testing.T) {
+	robots, err := parseInput("input.txt")
+	require.NoError(t, err)
+	var stats []FrameStats
+	for s := 8000; s < 8300; s++ {
+		stats = append(stats, Stats(robots, s, 101, 103))
+	}
+	var b strings.Builder
+	require.NoError(t, writeRankings(&b, stats, 2))
+	assert.Contains(t, b.String(), "By largest cluster:\n    8179: 229\n")
+	for _, m := range Metrics {
+		ranked := Rank(stats, m)
+		require.Len(t, ranked, len(stats))
+		for i := 1; i < len(ranked); i++ {
+			assert.GreaterOrEqual(t, m.Score(ranked[i-1]), m.Score(ranked[i]), m.Name)
+		}
+	}
+}
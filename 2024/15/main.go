@@ -2,132 +2,174 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
 	"log"
 	"os"
 	"strings"
+
+	"golang.org/x/term"
 )
 
 type Map struct {
 	lines  [][]byte
 	moves  []byte
 	rx, ry int // robot position
+
+	// If set, changes to the map are added to it.
+	record *[]Change
 }
 
-func (m *Map) canPush(x, y, dx, dy int) bool {
+func (m *Map) canPush(x, y, dx, dy int) (bool, error) {
 	x += dx
 	y += dy
 	switch m.lines[y][x] {
 	case '#':
-		return false
+		return false, nil
 	case '.':
-		return true
+		return true, nil
 	case 'O':
 		return m.canPush(x, y, dx, dy)
 	case '[':
 		if dy != 0 {
-			return m.canPush(x, y, dx, dy) && m.canPush(x+1, y, dx, dy)
+			return m.canPushBoth(x, x+1, y, dy)
 		} else {
 			return m.canPush(x, y, dx, dy)
 		}
 	case ']':
 		if dy != 0 {
-			return m.canPush(x, y, dx, dy) && m.canPush(x-1, y, dx, dy)
+			return m.canPushBoth(x-1, x, y, dy)
 		} else {
 			return m.canPush(x, y, dx, dy)
 		}
 	default:
-		panic("invalid character " + string(m.lines[y][x]))
+		return false, fmt.Errorf("invalid character %q at (%d, %d)", m.lines[y][x], x, y)
+	}
+}
+
+// canPushBoth checks if both halves of a wide box can be pushed vertically.
+func (m *Map) canPushBoth(x1, x2, y, dy int) (bool, error) {
+	ok, err := m.canPush(x1, y, 0, dy)
+	if !ok || err != nil {
+		return false, err
 	}
+	return m.canPush(x2, y, 0, dy)
+}
+
+// set changes a square of the map, recording the change if the map is being
+// recorded.
+func (m *Map) set(x, y int, c byte) {
+	if m.record != nil {
+		*m.record = append(*m.record, Change{x, y, m.lines[y][x], c})
+	}
+	m.lines[y][x] = c
 }
 
 // split into pushX and pushY?
 // keep a range of x values that need to be pushed?
 
-func (m *Map) doPushX(x, y, dx int, last byte) {
+func (m *Map) doPushX(x, y, dx int, last byte) error {
 	x += dx
 	switch m.lines[y][x] {
 	case '#':
 		if last != '.' {
-			panic("still holding a box")
+			return fmt.Errorf("still holding a box at (%d, %d)", x, y)
 		}
 	case '.':
-		m.lines[y][x] = last
+		m.set(x, y, last)
 	case 'O', '[', ']':
-		m.lines[y][x], last = last, m.lines[y][x]
-		m.doPushX(x, y, dx, last)
+		next := m.lines[y][x]
+		m.set(x, y, last)
+		return m.doPushX(x, y, dx, next)
 	default:
-		panic("invalid character " + string(m.lines[y][x]))
+		return fmt.Errorf("invalid character %q at (%d, %d)", m.lines[y][x], x, y)
 	}
+	return nil
 }
 
-func (m *Map) doPushY(x1, x2, y, dy int, last ...byte) {
+func (m *Map) doPushY(x1, x2, y, dy int, last ...byte) error {
 	y += dy
 	line := m.lines[y]
 	c := rune(line[x1])
 	c2 := rune(line[x2])
 	if c == '#' || c2 == '#' {
-		if last[x1] != '.' || last[x2] != '.' {
-			panic("still holding a box")
+		if last[0] != '.' || last[len(last)-1] != '.' {
+			return fmt.Errorf("still holding a box at (%d, %d)", x1, y)
 		}
-		return
+		return nil
 	}
-	line[x1] = last[0]
+	m.set(x1, y, last[0])
+	var err error
 	switch c {
 	case 'O':
-		m.doPushY(x1, x1, y, dy, 'O')
+		err = m.doPushY(x1, x1, y, dy, 'O')
 	case '[':
-		m.doPushY(x1, x1+1, y, dy, '[', ']')
-		line[x1+1] = '.'
+		err = m.doPushY(x1, x1+1, y, dy, '[', ']')
+		m.set(x1+1, y, '.')
 	case ']':
-		m.doPushY(x1-1, x1, y, dy, '[', ']')
-		line[x1-1] = '.'
+		err = m.doPushY(x1-1, x1, y, dy, '[', ']')
+		m.set(x1-1, y, '.')
 	}
-	if x2 == x1 {
-		return
+	if err != nil || x2 == x1 {
+		return err
 	}
-	line[x2] = last[1]
+	m.set(x2, y, last[1])
 	switch c2 {
 	case 'O':
-		m.doPushY(x2, x2, y, dy, 'O')
+		err = m.doPushY(x2, x2, y, dy, 'O')
 	case '[':
-		m.doPushY(x2, x2+1, y, dy, '[', ']')
-		line[x2+1] = '.'
+		err = m.doPushY(x2, x2+1, y, dy, '[', ']')
+		m.set(x2+1, y, '.')
+	}
+	return err
+}
+
+// direction returns the change in position for a move.
+func direction(move byte) (dx, dy int, err error) {
+	switch move {
+	case '^':
+		return 0, -1, nil
+	case 'v':
+		return 0, 1, nil
+	case '<':
+		return -1, 0, nil
+	case '>':
+		return 1, 0, nil
+	}
+	return 0, 0, fmt.Errorf("invalid move %q", move)
+}
+
+// Move moves the robot once, pushing any boxes in the way. It returns false
+// if the robot can't move.
+func (m *Map) Move(move byte) (bool, error) {
+	dx, dy, err := direction(move)
+	if err != nil {
+		return false, err
+	}
+	ok, err := m.canPush(m.rx, m.ry, dx, dy)
+	if !ok || err != nil {
+		return false, err
+	}
+	if dy != 0 {
+		err = m.doPushY(m.rx, m.rx, m.ry, dy, '.')
+	} else {
+		err = m.doPushX(m.rx, m.ry, dx, '.')
+	}
+	if err != nil {
+		return false, err
 	}
+	m.rx += dx
+	m.ry += dy
+	return true, nil
 }
 
-func (m *Map) Run() {
-	// var step int
+func (m *Map) Run() error {
 	for _, move := range m.moves {
-		// fmt.Printf("Step %d - move %c\n", step, move)
-		// m.Print()
-		// step++
-
-		var dx, dy int
-		switch move {
-		case '^':
-			dx = 0
-			dy = -1
-		case 'v':
-			dx = 0
-			dy = 1
-		case '<':
-			dx = -1
-			dy = 0
-		case '>':
-			dx = 1
-			dy = 0
-		}
-		if m.canPush(m.rx, m.ry, dx, dy) {
-			if dy != 0 {
-				m.doPushY(m.rx, m.rx, m.ry, dy, '.')
-			} else {
-				m.doPushX(m.rx, m.ry, dx, '.')
-			}
-			m.rx += dx
-			m.ry += dy
+		if _, err := m.Move(move); err != nil {
+			return err
 		}
 	}
+	return nil
 }
 
 func (m *Map) Sum() int {
@@ -142,34 +184,53 @@ func (m *Map) Sum() int {
 	return sum
 }
 
-func (m *Map) Print() {
+func (m *Map) String() string {
+	var b strings.Builder
 	for y, line := range m.lines {
 		for x, c := range line {
 			if x == m.rx && y == m.ry {
-				fmt.Print("@")
+				b.WriteByte('@')
 			} else {
-				fmt.Print(string(c))
+				b.WriteByte(c)
 			}
 		}
-		fmt.Println()
+		b.WriteByte('\n')
 	}
-	fmt.Println()
+	return b.String()
+}
+
+func (m *Map) Print() {
+	fmt.Println(m.String())
 }
 
-func (m *Map) findRobot() {
+// findRobot finds the robot, and replaces it with an empty square. It returns
+// false if there is no robot.
+func (m *Map) findRobot() bool {
 	for y, line := range m.lines {
 		for x, c := range line {
 			if c == '@' {
 				line[x] = '.'
 				m.rx, m.ry = x, y
-				return
+				return true
 			}
 		}
 	}
-	panic("robot not found")
+	return false
 }
 
 func parseInput(filename string, parseLine func(string) []byte) (*Map, error) {
+	m, err := readMap(filename, parseLine)
+	if err != nil {
+		return nil, err
+	}
+	if !m.findRobot() {
+		return nil, fmt.Errorf("%s: robot not found", filename)
+	}
+	return m, nil
+}
+
+// readMap reads a map and its moves, without looking for the robot.
+func readMap(filename string, parseLine func(string) []byte) (*Map, error) {
 	file, err := os.Open(filename)
 	if err != nil {
 		return nil, err
@@ -187,7 +248,6 @@ func parseInput(filename string, parseLine func(string) []byte) (*Map, error) {
 		}
 		m.lines = append(m.lines, parseLine(line))
 	}
-	m.findRobot()
 
 	// Scan the moves
 	for scanner.Scan() {
@@ -225,20 +285,130 @@ func parseInput2(filename string) (*Map, error) {
 	})
 }
 
-func Part1(filename string) int {
-	m, err := parseInput1(filename)
+// Scaled returns the GPS sum after the robot has made all its moves, in the
+// warehouse scaled up to w×h.
+func Scaled(filename string, w, h int) int {
+	wh, err := parseWarehouse(filename, w, h)
 	if err != nil {
 		log.Fatal(err)
 	}
-	m.Run()
-	return m.Sum()
+	if err := wh.Run(); err != nil {
+		log.Fatal(err)
+	}
+	return wh.Sum()
+}
+
+func Part1(filename string) int {
+	return Scaled(filename, 1, 1)
 }
 
 func Part2(filename string) int {
-	m, err := parseInput2(filename)
+	return Scaled(filename, 2, 1)
+}
+
+// plan searches for the moves that reach a target, and prints them along with
+// the map as a new puzzle.
+func plan(filename, target string, targetSum int, wide bool, maxStates int) error {
+	w := 1
+	if wide {
+		w = 2
+	}
+	m, err := parseInput1(filename)
+	if err != nil {
+		return err
+	}
+	wh, err := NewWarehouse(m, w, 1)
+	if err != nil {
+		return err
+	}
+	var moves []byte
+	if target != "" {
+		goal, err := parseLayout(target, w, 1)
+		if err != nil {
+			return err
+		}
+		moves, err = PlanLayout(wh, goal, maxStates)
+		if err != nil {
+			return err
+		}
+	} else {
+		if moves, err = PlanSum(wh, targetSum, maxStates); err != nil {
+			return err
+		}
+	}
+
+	// Check the plan by replaying it.
+	wh.moves = moves
+	if err := wh.Run(); err != nil {
+		return err
+	}
+	fmt.Print(m.String())
+	for len(moves) > 70 {
+		fmt.Printf("\n%s", moves[:70])
+		moves = moves[70:]
+	}
+	fmt.Printf("\n%s\n", moves)
+	log.Printf("%d moves, GPS sum %d", len(wh.moves), wh.Sum())
+	return nil
+}
+
+func main() {
+	wide := flag.Bool("wide", false, "use the wide warehouse from Part 2")
+	interactive := flag.Bool("interactive", false, "step through the moves with the arrow keys")
+	move := flag.Int("move", -1, "print the warehouse after this many moves")
+	scaleW := flag.Int("box-width", 0, "scale the warehouse so boxes are this wide, and print the GPS sum")
+	scaleH := flag.Int("box-height", 1, "scale the warehouse so boxes are this high, with -box-width")
+	target := flag.String("target", "", "find the shortest moves that put the boxes where they are in this map")
+	targetSum := flag.Int("target-sum", -1, "find the shortest moves that give this GPS sum")
+	maxStates := flag.Int("max-states", 200000, "give up planning after this many states, each of which takes a few bytes for each box")
+	flag.Parse()
+
+	if *scaleW > 0 {
+		fmt.Printf("GPS sum: %d\n", Scaled(flag.Arg(0), *scaleW, *scaleH))
+		return
+	}
+	if *target != "" || *targetSum >= 0 {
+		if err := plan(flag.Arg(0), *target, *targetSum, *wide, *maxStates); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if !*interactive && *move < 0 {
+		fmt.Printf("Part 1: %d\n", Part1(flag.Arg(0)))
+		fmt.Printf("Part 2: %d\n", Part2(flag.Arg(0)))
+		return
+	}
+
+	parse := parseInput1
+	if *wide {
+		parse = parseInput2
+	}
+	m, err := parse(flag.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	s, err := NewSimulator(m)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *move >= 0 {
+		if err := s.Seek(*move); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if !*interactive {
+		m.Print()
+		fmt.Printf("GPS sum: %d\n", m.Sum())
+		return
+	}
+
+	state, err := term.MakeRaw(int(os.Stdin.Fd()))
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = interact(s, os.Stdin, os.Stdout)
+	term.Restore(int(os.Stdin.Fd()), state)
 	if err != nil {
 		log.Fatal(err)
 	}
-	m.Run()
-	return m.Sum()
 }
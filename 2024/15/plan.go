@@ -0,0 +1,209 @@
+package main
+
+import (
+	"cmp"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+)
+
+var (
+	ErrNoPlan        = errors.New("the goal can't be reached")
+	ErrTooManyStates = errors.New("gave up searching")
+)
+
+// clone returns a copy of the warehouse that can be moved independently. The
+// walls never change, so they are shared.
+func (wh *Warehouse) clone() *Warehouse {
+	c := *wh
+	c.boxes = slices.Clone(wh.boxes)
+	c.owner = make([][]int, len(wh.owner))
+	for y, row := range wh.owner {
+		c.owner[y] = slices.Clone(row)
+	}
+	return &c
+}
+
+// sortedBoxes returns the boxes grouped by size, and in reading order within
+// each size. Boxes of the same size are interchangeable, so this is all that
+// matters about where they are. Moving boxes never changes their sizes, so
+// the sizes always come in the same order.
+func (wh *Warehouse) sortedBoxes() []Box {
+	boxes := slices.Clone(wh.boxes)
+	slices.SortFunc(boxes, compareBoxes)
+	return boxes
+}
+
+func compareBoxes(a, b Box) int {
+	return cmp.Or(cmp.Compare(a.H, b.H), cmp.Compare(a.W, b.W), cmp.Compare(a.Y, b.Y), cmp.Compare(a.X, b.X))
+}
+
+// key identifies the state of the warehouse: where the robot and boxes are.
+// Since the sizes of the boxes never change, only their positions are
+// needed, two bytes for each coordinate.
+func (wh *Warehouse) key() string {
+	b := make([]byte, 0, 4+4*len(wh.boxes))
+	b = binary.BigEndian.AppendUint16(b, uint16(wh.rx))
+	b = binary.BigEndian.AppendUint16(b, uint16(wh.ry))
+	for _, box := range wh.sortedBoxes() {
+		b = binary.BigEndian.AppendUint16(b, uint16(box.X))
+		b = binary.BigEndian.AppendUint16(b, uint16(box.Y))
+	}
+	return string(b)
+}
+
+// load puts the robot and boxes where key says, undoing any moves made since
+// the key was taken. The boxes must be in the order given by sortedBoxes.
+func (wh *Warehouse) load(key string) {
+	u := func(i int) int {
+		return int(key[2*i])<<8 | int(key[2*i+1])
+	}
+	for i := range wh.boxes {
+		wh.place(i, 0)
+	}
+	wh.rx, wh.ry = u(0), u(1)
+	for i := range wh.boxes {
+		wh.boxes[i].X, wh.boxes[i].Y = u(2+2*i), u(3+2*i)
+		wh.place(i, i+1)
+	}
+}
+
+// frozen returns true if nothing can ever move the box, because of the walls
+// around it. A box can't be pushed up if any square above it is a wall, and
+// can't be pushed down unless something can get above it to push it, which
+// isn't possible if every square above it is a wall. The same goes for the
+// other directions.
+func (wh *Warehouse) frozen(b Box) bool {
+	edge := func(x0, y0, dx, dy, n int) (anyWall, allWalls bool) {
+		allWalls = true
+		for i := range n {
+			if wh.blocked(x0+i*dx, y0+i*dy) {
+				anyWall = true
+			} else {
+				allWalls = false
+			}
+		}
+		return anyWall, allWalls
+	}
+	anyUp, allUp := edge(b.X, b.Y-1, 1, 0, b.W)
+	anyDown, allDown := edge(b.X, b.Y+b.H, 1, 0, b.W)
+	anyLeft, allLeft := edge(b.X-1, b.Y, 0, 1, b.H)
+	anyRight, allRight := edge(b.X+b.W, b.Y, 0, 1, b.H)
+	vertical := (anyUp && anyDown) || allUp || allDown
+	horizontal := (anyLeft && anyRight) || allLeft || allRight
+	return vertical && horizontal
+}
+
+// Plan finds the shortest sequence of moves after which done returns true,
+// by breadth first search over the positions of the robot and the boxes. If
+// dead is not nil, it is used to skip states that can't lead to the goal. It
+// gives up after looking at maxStates states.
+//
+// Only the key of each state is kept, and a single copy of the warehouse is
+// loaded with each state in turn to try the moves from it. done and dead are
+// passed that copy, so they must not hold on to it.
+func Plan(wh *Warehouse, done, dead func(*Warehouse) bool, maxStates int) ([]byte, error) {
+	if wh.Width > math.MaxUint16 || wh.Height > math.MaxUint16 {
+		return nil, fmt.Errorf("the warehouse is too big to plan for: %d×%d", wh.Width, wh.Height)
+	}
+	type node struct {
+		key    string
+		parent int
+		move   byte
+	}
+	scratch := wh.clone()
+	// Put the boxes in key order, so that load can use them as they are.
+	for i := range scratch.boxes {
+		scratch.place(i, 0)
+	}
+	slices.SortFunc(scratch.boxes, compareBoxes)
+	start := scratch.key()
+	scratch.load(start)
+
+	nodes := []node{{start, -1, 0}}
+	seen := map[string]bool{start: true}
+	for i := 0; i < len(nodes); i++ {
+		scratch.load(nodes[i].key)
+		if done(scratch) {
+			var moves []byte
+			for n := nodes[i]; n.parent >= 0; n = nodes[n.parent] {
+				moves = append(moves, n.move)
+			}
+			slices.Reverse(moves)
+			return moves, nil
+		}
+		moved := false
+		for _, move := range []byte("^v<>") {
+			if moved {
+				scratch.load(nodes[i].key)
+			}
+			ok, _ := scratch.Move(move)
+			if moved = ok; !ok {
+				continue
+			}
+			key := scratch.key()
+			if seen[key] || (dead != nil && dead(scratch)) {
+				continue
+			}
+			if len(seen) >= maxStates {
+				return nil, fmt.Errorf("%w after %d states", ErrTooManyStates, len(seen))
+			}
+			seen[key] = true
+			nodes = append(nodes, node{key, i, move})
+		}
+	}
+	return nil, ErrNoPlan
+}
+
+// PlanLayout finds the shortest sequence of moves that puts boxes at every
+// one of the target positions. States where a box is stuck somewhere other
+// than a target are skipped.
+func PlanLayout(wh *Warehouse, target []Box, maxStates int) ([]byte, error) {
+	if len(target) != len(wh.boxes) {
+		return nil, fmt.Errorf("%w: there are %d boxes, but %d targets", ErrNoPlan, len(wh.boxes), len(target))
+	}
+	target = slices.Clone(target)
+	slices.SortFunc(target, compareBoxes)
+	done := func(wh *Warehouse) bool {
+		return slices.Equal(wh.sortedBoxes(), target)
+	}
+	dead := func(wh *Warehouse) bool {
+		for _, b := range wh.boxes {
+			if wh.frozen(b) && !slices.Contains(target, b) {
+				return true
+			}
+		}
+		return false
+	}
+	if dead(wh) {
+		return nil, ErrNoPlan
+	}
+	return Plan(wh, done, dead, maxStates)
+}
+
+// PlanSum finds the shortest sequence of moves that leaves the boxes with the
+// given GPS sum.
+func PlanSum(wh *Warehouse, sum int, maxStates int) ([]byte, error) {
+	return Plan(wh, func(wh *Warehouse) bool {
+		return wh.Sum() == sum
+	}, nil, maxStates)
+}
+
+// parseLayout reads the boxes from a map, and scales them up to w×h. Unlike a
+// puzzle input, the map doesn't need a robot.
+func parseLayout(filename string, w, h int) ([]Box, error) {
+	m, err := readMap(filename, func(line string) []byte {
+		return []byte(line)
+	})
+	if err != nil {
+		return nil, err
+	}
+	m.findRobot()
+	wh, err := NewWarehouse(m, w, h)
+	if err != nil {
+		return nil, err
+	}
+	return wh.boxes, nil
+}
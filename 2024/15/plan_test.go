@@ -0,0 +1,147 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func makeWarehouse(t *testing.T, w int, lines ...string) *Warehouse {
+	t.Helper()
+	m := &Map{}
+	for _, line := range lines {
+		m.lines = append(m.lines, []byte(line))
+	}
+	require.True(t, m.findRobot())
+	wh, err := NewWarehouse(m, w, 1)
+	require.NoError(t, err)
+	return wh
+}
+
+func TestPlanLayout(t *testing.T) {
+	wh := makeWarehouse(t, 1,
+		"#######",
+		"#.....#",
+		"#.O.O.#",
+		"#..@..#",
+		"#.....#",
+		"#######",
+	)
+	target := []Box{{2, 4, 1, 1}, {3, 4, 1, 1}}
+	moves, err := PlanLayout(wh, target, 100000)
+	require.NoError(t, err)
+	assert.Len(t, moves, 13)
+
+	wh.moves = moves
+	require.NoError(t, wh.Run())
+	assert.Equal(t, target, wh.sortedBoxes())
+
+	// Putting a box in a corner is fine if that's where it should be, but
+	// nothing can get it out again.
+	wh = makeWarehouse(t, 1,
+		"#####",
+		"#O..#",
+		"#.O@#",
+		"#####",
+	)
+	_, err = PlanLayout(wh, []Box{{1, 1, 1, 1}, {1, 2, 1, 1}}, 100000)
+	require.NoError(t, err)
+	_, err = PlanLayout(wh, []Box{{2, 1, 1, 1}, {1, 2, 1, 1}}, 100000)
+	assert.ErrorIs(t, err, ErrNoPlan)
+	_, err = PlanLayout(wh, []Box{{2, 1, 1, 1}}, 100000)
+	assert.ErrorIs(t, err, ErrNoPlan)
+}
+
+func TestFrozen(t *testing.T) {
+	wh := makeWarehouse(t, 2,
+		"#####",
+		"#...#",
+		"#.@.#",
+		"#####",
+	)
+	for _, tc := range []struct {
+		box  Box
+		want bool
+	}{
+		{Box{2, 1, 1, 1}, true},
+		{Box{3, 1, 1, 1}, false},
+		{Box{2, 2, 1, 1}, true},
+		{Box{5, 1, 2, 1}, false},
+		// Wide boxes can slide along walls, but not out of corners.
+		{Box{2, 1, 2, 1}, true},
+		{Box{6, 2, 2, 1}, true},
+		// A tall box against the top and bottom walls can still move
+		// sideways.
+		{Box{4, 1, 1, 2}, false},
+	} {
+		assert.Equal(t, tc.want, wh.frozen(tc.box), "%+v", tc.box)
+	}
+}
+
+func TestPlanSum(t *testing.T) {
+	m, err := parseInput1("test1b.txt")
+	require.NoError(t, err)
+	wh, err := NewWarehouse(m, 1, 1)
+	require.NoError(t, err)
+	moves, err := PlanSum(wh, 2028, 100000)
+	require.NoError(t, err)
+	// The puzzle's own moves get there too, but not as quickly.
+	assert.Less(t, len(moves), len(m.moves))
+
+	wh.moves = moves
+	require.NoError(t, wh.Run())
+	assert.Equal(t, 2028, wh.Sum())
+
+	wh, err = NewWarehouse(m, 1, 1)
+	require.NoError(t, err)
+	_, err = PlanSum(wh, 2028, 10)
+	assert.ErrorIs(t, err, ErrTooManyStates)
+}
+
+func TestParseLayout(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "layout.txt")
+	require.NoError(t, os.WriteFile(name, []byte("#####\n#.O.#\n#O..#\n#####\n"), 0o644))
+	boxes, err := parseLayout(name, 2, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []Box{{4, 1, 2, 1}, {2, 2, 2, 1}}, boxes)
+
+	// A puzzle input has to have a robot, though.
+	_, err = parseInput1(name)
+	assert.ErrorContains(t, err, "robot not found")
+}
+
+func TestPlanMemory(t *testing.T) {
+	if testing.Short() {
+		t.Skip("slow")
+	}
+	wh, err := parseWarehouse("input.txt", 1, 1)
+	require.NoError(t, err)
+
+	// Keep track of the most memory in use while searching, not counting
+	// garbage. Each state only needs a few bytes for each box, so 20000 of
+	// them fit easily in 100 MB.
+	var ms runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&ms)
+	base, peak := ms.HeapAlloc, ms.HeapAlloc
+	var states int
+	dead := func(*Warehouse) bool {
+		if states++; states%2000 == 0 {
+			runtime.GC()
+			runtime.ReadMemStats(&ms)
+			peak = max(peak, ms.HeapAlloc)
+		}
+		return false
+	}
+	done := func(wh *Warehouse) bool {
+		return wh.Sum() == 1
+	}
+	_, err = Plan(wh, done, dead, 20000)
+	assert.ErrorIs(t, err, ErrTooManyStates)
+	assert.Less(t, peak-base, uint64(100<<20))
+}
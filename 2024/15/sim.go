@@ -0,0 +1,182 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Change is a change to one square of the map.
+type Change struct {
+	X, Y     int
+	Old, New byte
+}
+
+// Event records the effect of one move, so that it can be undone.
+type Event struct {
+	Move byte
+	// The robot's position before and after the move. They are the same
+	// if the robot couldn't move.
+	FromX, FromY int
+	ToX, ToY     int
+	Changes      []Change
+}
+
+// Simulator steps through the moves of a map, one at a time, in either
+// direction. After every step it checks that the map still makes sense.
+type Simulator struct {
+	m      *Map
+	events []Event // the moves made so far, including any undone ones
+	pos    int     // the number of moves applied
+	boxes  int     // the number of boxes on the map
+}
+
+// ErrInvariant is wrapped by the errors returned when a map stops making
+// sense.
+var ErrInvariant = errors.New("invalid warehouse")
+
+// NewSimulator returns a simulator for m, which it takes ownership of. No
+// moves have been made yet.
+func NewSimulator(m *Map) (*Simulator, error) {
+	s := &Simulator{m: m}
+	boxes, err := m.check()
+	if err != nil {
+		return nil, err
+	}
+	s.boxes = boxes
+	return s, nil
+}
+
+// check makes sure that every wide box has both halves, and that the robot is
+// on an empty square. It returns the number of boxes.
+func (m *Map) check() (int, error) {
+	var boxes int
+	for y, line := range m.lines {
+		for x, c := range line {
+			switch c {
+			case '#', '.':
+			case 'O':
+				boxes++
+			case '[':
+				if x+1 >= len(line) || line[x+1] != ']' {
+					return 0, fmt.Errorf("%w: box at (%d, %d) has no right half", ErrInvariant, x, y)
+				}
+				boxes++
+			case ']':
+				if x == 0 || line[x-1] != '[' {
+					return 0, fmt.Errorf("%w: box at (%d, %d) has no left half", ErrInvariant, x, y)
+				}
+			default:
+				return 0, fmt.Errorf("%w: invalid character %q at (%d, %d)", ErrInvariant, c, x, y)
+			}
+		}
+	}
+	if c := m.lines[m.ry][m.rx]; c != '.' {
+		return 0, fmt.Errorf("%w: robot at (%d, %d) is on %q", ErrInvariant, m.rx, m.ry, c)
+	}
+	return boxes, nil
+}
+
+// Map returns the map as of the current move. It must not be changed.
+func (s *Simulator) Map() *Map {
+	return s.m
+}
+
+// Pos returns the number of moves that have been made.
+func (s *Simulator) Pos() int {
+	return s.pos
+}
+
+// Len returns the total number of moves.
+func (s *Simulator) Len() int {
+	return len(s.m.moves)
+}
+
+// Last returns the most recent move, if there is one.
+func (s *Simulator) Last() (Event, bool) {
+	if s.pos == 0 {
+		return Event{}, false
+	}
+	return s.events[s.pos-1], true
+}
+
+// Forward makes the next move. It returns false if there are no moves left.
+func (s *Simulator) Forward() (bool, error) {
+	if s.pos == len(s.m.moves) {
+		return false, nil
+	}
+	if s.pos < len(s.events) {
+		// The move was undone, so redo it.
+		e := s.events[s.pos]
+		for _, c := range e.Changes {
+			s.m.lines[c.Y][c.X] = c.New
+		}
+		s.m.rx, s.m.ry = e.ToX, e.ToY
+		s.pos++
+		return true, nil
+	}
+
+	m := s.m
+	e := Event{Move: m.moves[s.pos], FromX: m.rx, FromY: m.ry}
+	m.record = &e.Changes
+	_, err := m.Move(e.Move)
+	m.record = nil
+	e.ToX, e.ToY = m.rx, m.ry
+	if err == nil {
+		err = s.verify()
+	}
+	if err != nil {
+		// Put things back the way they were, so that the state
+		// reflects the last good move.
+		s.undo(e)
+		return false, fmt.Errorf("move %d (%c): %w", s.pos+1, e.Move, err)
+	}
+	s.events = append(s.events, e)
+	s.pos++
+	return true, nil
+}
+
+// verify checks the map after a move.
+func (s *Simulator) verify() error {
+	boxes, err := s.m.check()
+	if err != nil {
+		return err
+	}
+	if boxes != s.boxes {
+		return fmt.Errorf("%w: there are %d boxes, not %d", ErrInvariant, boxes, s.boxes)
+	}
+	return nil
+}
+
+func (s *Simulator) undo(e Event) {
+	for i := len(e.Changes) - 1; i >= 0; i-- {
+		c := e.Changes[i]
+		s.m.lines[c.Y][c.X] = c.Old
+	}
+	s.m.rx, s.m.ry = e.FromX, e.FromY
+}
+
+// Back undoes the last move. It returns false if no moves have been made.
+func (s *Simulator) Back() bool {
+	if s.pos == 0 {
+		return false
+	}
+	s.pos--
+	s.undo(s.events[s.pos])
+	return true
+}
+
+// Seek moves forward or back until n moves have been made.
+func (s *Simulator) Seek(n int) error {
+	if n < 0 || n > len(s.m.moves) {
+		return fmt.Errorf("move %d is out of range [0, %d]", n, len(s.m.moves))
+	}
+	for s.pos > n {
+		s.Back()
+	}
+	for s.pos < n {
+		if _, err := s.Forward(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSimulator(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		parse func(string) (*Map, error)
+		sum   int
+	}{
+		{"narrow", parseInput1, 10092},
+		{"wide", parseInput2, 9021},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			m, err := tc.parse("test1a.txt")
+			require.NoError(t, err)
+			start := m.String()
+			s, err := NewSimulator(m)
+			require.NoError(t, err)
+
+			// Record the map after each move.
+			frames := []string{start}
+			for {
+				ok, err := s.Forward()
+				require.NoError(t, err)
+				if !ok {
+					break
+				}
+				frames = append(frames, s.Map().String())
+			}
+			require.Len(t, frames, s.Len()+1)
+			assert.Equal(t, tc.sum, s.Map().Sum())
+
+			// Going back retraces the same steps.
+			for i := s.Len() - 1; i >= 0; i-- {
+				require.True(t, s.Back())
+				require.Equal(t, frames[i], s.Map().String(), "move %d", i)
+			}
+			assert.False(t, s.Back())
+
+			for _, n := range []int{500, 3, 3, 700, 0, 250} {
+				require.NoError(t, s.Seek(n))
+				assert.Equal(t, n, s.Pos())
+				assert.Equal(t, frames[n], s.Map().String(), "move %d", n)
+			}
+			assert.Error(t, s.Seek(s.Len()+1))
+		})
+	}
+}
+
+func TestInvariants(t *testing.T) {
+	m, err := parseInput2("test1b.txt")
+	require.NoError(t, err)
+	// Break the box to the right of the robot in half.
+	//   ####@...[]....##
+	m.lines[m.ry][m.rx+4] = '.'
+	_, err = NewSimulator(m)
+	assert.ErrorIs(t, err, ErrInvariant)
+
+	// The same thing, but after the simulator has started.
+	m, err = parseInput2("test1b.txt")
+	require.NoError(t, err)
+	s, err := NewSimulator(m)
+	require.NoError(t, err)
+	m.lines[m.ry][m.rx+4] = '.'
+	before := m.String()
+	_, err = s.Forward()
+	assert.ErrorIs(t, err, ErrInvariant)
+	assert.ErrorContains(t, err, "move 1 (<)")
+	// The failed move is undone.
+	assert.Equal(t, 0, s.Pos())
+	assert.Equal(t, before, m.String())
+}
+
+func TestInteract(t *testing.T) {
+	m, err := parseInput1("test1a.txt")
+	require.NoError(t, err)
+	s, err := NewSimulator(m)
+	require.NoError(t, err)
+	var out strings.Builder
+	// Right twice, left once, then jump ahead.
+	in := strings.NewReader("\x1b[C\x1b[C\x1b[D\x1b[A")
+	require.NoError(t, interact(s, in, &out))
+	assert.Equal(t, 1+jump, s.Pos())
+	assert.Contains(t, out.String(), "Move 101/700: ")
+
+	// Go to the end, then quit before Home is read.
+	in = strings.NewReader("\x1b[Fq\x1b[H")
+	require.NoError(t, interact(s, in, &out))
+	assert.Equal(t, s.Len(), s.Pos())
+	assert.Equal(t, 10092, s.Map().Sum())
+}
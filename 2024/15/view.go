@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// The keys the viewer understands.
+type key int
+
+const (
+	keyNone key = iota
+	keyLeft
+	keyRight
+	keyUp
+	keyDown
+	keyHome
+	keyEnd
+	keyQuit
+)
+
+// readKey decodes the first key pressed in buf, returning the number of bytes
+// it took up. Unknown keys are skipped.
+func readKey(buf []byte) (key, int) {
+	if len(buf) >= 3 && buf[0] == '\x1b' && buf[1] == '[' {
+		switch buf[2] {
+		case 'A':
+			return keyUp, 3
+		case 'B':
+			return keyDown, 3
+		case 'C':
+			return keyRight, 3
+		case 'D':
+			return keyLeft, 3
+		case 'H':
+			return keyHome, 3
+		case 'F':
+			return keyEnd, 3
+		}
+		return keyNone, 3
+	}
+	switch buf[0] {
+	case 'q', 3: // 3 is Ctrl-C
+		return keyQuit, 1
+	}
+	return keyNone, 1
+}
+
+// jump is how many moves the up and down keys skip.
+const jump = 100
+
+// render draws the map, followed by a line describing the last move. Lines
+// end in "\r\n", as the terminal is in raw mode.
+func render(s *Simulator) string {
+	var b strings.Builder
+	b.WriteString("\x1b[H\x1b[2J")
+	b.WriteString(strings.ReplaceAll(s.Map().String(), "\n", "\r\n"))
+	fmt.Fprintf(&b, "Move %d/%d", s.Pos(), s.Len())
+	if e, ok := s.Last(); ok {
+		fmt.Fprintf(&b, ": %c", e.Move)
+		if e.FromX == e.ToX && e.FromY == e.ToY {
+			b.WriteString(" (blocked)")
+		}
+	}
+	fmt.Fprintf(&b, "  GPS sum %d\r\n", s.Map().Sum())
+	fmt.Fprintf(&b, "←/→ step, ↓/↑ jump %d, Home/End, q to quit\r\n", jump)
+	return b.String()
+}
+
+// interact shows the simulation on out, and steps through it as keys are
+// read from in. It returns when the user quits or in runs out.
+func interact(s *Simulator, in io.Reader, out io.Writer) error {
+	buf := make([]byte, 16)
+	for {
+		if _, err := io.WriteString(out, render(s)); err != nil {
+			return err
+		}
+		n, err := in.Read(buf)
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		for i := 0; i < n; {
+			k, size := readKey(buf[i:n])
+			i += size
+			switch k {
+			case keyLeft:
+				s.Back()
+			case keyRight:
+				_, err = s.Forward()
+			case keyUp:
+				err = s.Seek(min(s.Pos()+jump, s.Len()))
+			case keyDown:
+				err = s.Seek(max(s.Pos()-jump, 0))
+			case keyHome:
+				err = s.Seek(0)
+			case keyEnd:
+				err = s.Seek(s.Len())
+			case keyQuit:
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Box is a rectangular box, W squares wide and H high, with its top left
+// corner at (X, Y).
+type Box struct {
+	X, Y, W, H int
+}
+
+// Warehouse is a map where boxes can be any size. Rather than special-casing
+// each kind of box, a move finds every box that would be pushed, and moves
+// them all at once.
+type Warehouse struct {
+	Width, Height int
+	walls         [][]bool
+	boxes         []Box
+	// owner holds 1 + the index of the box covering each square, or 0 for
+	// none.
+	owner  [][]int
+	rx, ry int
+	moves  []byte
+}
+
+// NewWarehouse makes a warehouse from a map, scaling everything in it up to
+// w×h. Each box becomes a single w×h box, and the robot starts in the top left
+// corner of its scaled up square. With a scale of 1×1 it is the warehouse from
+// Part 1, and with 2×1 it is the wide warehouse from Part 2. The map must
+// only use '#', '.' and 'O'.
+func NewWarehouse(m *Map, w, h int) (*Warehouse, error) {
+	if w < 1 || h < 1 {
+		return nil, fmt.Errorf("invalid scale %d×%d", w, h)
+	}
+	wh := &Warehouse{
+		Height: len(m.lines) * h,
+		rx:     m.rx * w,
+		ry:     m.ry * h,
+		moves:  m.moves,
+	}
+	for _, line := range m.lines {
+		wh.Width = max(wh.Width, len(line)*w)
+	}
+	wh.walls = make([][]bool, wh.Height)
+	wh.owner = make([][]int, wh.Height)
+	for y := range wh.Height {
+		wh.walls[y] = make([]bool, wh.Width)
+		wh.owner[y] = make([]int, wh.Width)
+	}
+	for y, line := range m.lines {
+		for x, c := range line {
+			switch c {
+			case '#':
+				for dy := range h {
+					for dx := range w {
+						wh.walls[y*h+dy][x*w+dx] = true
+					}
+				}
+			case 'O':
+				wh.add(Box{x * w, y * h, w, h})
+			case '.':
+			default:
+				return nil, fmt.Errorf("invalid character %q at (%d, %d)", c, x, y)
+			}
+		}
+	}
+	return wh, nil
+}
+
+// add puts a new box in the warehouse.
+func (wh *Warehouse) add(b Box) {
+	wh.boxes = append(wh.boxes, b)
+	wh.place(len(wh.boxes)-1, len(wh.boxes))
+}
+
+// place marks the squares covered by box i as belonging to id.
+func (wh *Warehouse) place(i, id int) {
+	b := wh.boxes[i]
+	for y := b.Y; y < b.Y+b.H; y++ {
+		for x := b.X; x < b.X+b.W; x++ {
+			wh.owner[y][x] = id
+		}
+	}
+}
+
+// blocked returns true if (x, y) is a wall, or outside the warehouse.
+func (wh *Warehouse) blocked(x, y int) bool {
+	return y < 0 || y >= wh.Height || x < 0 || x >= wh.Width || wh.walls[y][x]
+}
+
+// pushSet returns the boxes that would move if something at the given
+// squares moved by (dx, dy). These are the boxes in the way, the boxes in the
+// way of those, and so on. It returns false if any of them would hit a wall.
+func (wh *Warehouse) pushSet(squares [][2]int, dx, dy int) ([]int, bool) {
+	var set []int
+	seen := make(map[int]bool)
+	visit := func(x, y int) bool {
+		x, y = x+dx, y+dy
+		if wh.blocked(x, y) {
+			return false
+		}
+		if id := wh.owner[y][x]; id != 0 && !seen[id-1] {
+			seen[id-1] = true
+			set = append(set, id-1)
+		}
+		return true
+	}
+	for _, sq := range squares {
+		if !visit(sq[0], sq[1]) {
+			return nil, false
+		}
+	}
+	for i := 0; i < len(set); i++ {
+		b := wh.boxes[set[i]]
+		for y := b.Y; y < b.Y+b.H; y++ {
+			for x := b.X; x < b.X+b.W; x++ {
+				if !visit(x, y) {
+					return nil, false
+				}
+			}
+		}
+	}
+	return set, true
+}
+
+// Move moves the robot once, pushing any boxes in the way. It returns false
+// if the robot can't move.
+func (wh *Warehouse) Move(move byte) (bool, error) {
+	dx, dy, err := direction(move)
+	if err != nil {
+		return false, err
+	}
+	set, ok := wh.pushSet([][2]int{{wh.rx, wh.ry}}, dx, dy)
+	if !ok {
+		return false, nil
+	}
+	for _, i := range set {
+		wh.place(i, 0)
+	}
+	for _, i := range set {
+		wh.boxes[i].X += dx
+		wh.boxes[i].Y += dy
+		wh.place(i, i+1)
+	}
+	wh.rx += dx
+	wh.ry += dy
+	return true, nil
+}
+
+func (wh *Warehouse) Run() error {
+	for _, move := range wh.moves {
+		if _, err := wh.Move(move); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Sum returns the sum of the GPS coordinates of the top left corner of each
+// box.
+func (wh *Warehouse) Sum() int {
+	var sum int
+	for _, b := range wh.boxes {
+		sum += 100*b.Y + b.X
+	}
+	return sum
+}
+
+// String draws the warehouse the same way as a Map. Boxes one square wide are
+// drawn as 'O', and wider boxes as '[', any number of '=', and ']'.
+func (wh *Warehouse) String() string {
+	var s strings.Builder
+	for y := range wh.Height {
+		for x := range wh.Width {
+			switch {
+			case x == wh.rx && y == wh.ry:
+				s.WriteByte('@')
+			case wh.walls[y][x]:
+				s.WriteByte('#')
+			case wh.owner[y][x] == 0:
+				s.WriteByte('.')
+			default:
+				b := wh.boxes[wh.owner[y][x]-1]
+				switch {
+				case b.W == 1:
+					s.WriteByte('O')
+				case x == b.X:
+					s.WriteByte('[')
+				case x == b.X+b.W-1:
+					s.WriteByte(']')
+				default:
+					s.WriteByte('=')
+				}
+			}
+		}
+		s.WriteByte('\n')
+	}
+	return s.String()
+}
+
+// parseWarehouse reads a map, and scales it up to w×h.
+func parseWarehouse(filename string, w, h int) (*Warehouse, error) {
+	m, err := parseInput1(filename)
+	if err != nil {
+		return nil, err
+	}
+	return NewWarehouse(m, w, h)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestWarehouse runs the old and new engines side by side, and checks that
+// they agree after every move.
+func TestWarehouse(t *testing.T) {
+	for _, filename := range []string{"test1a.txt", "test1b.txt", "input.txt"} {
+		for _, tc := range []struct {
+			w     int
+			parse func(string) (*Map, error)
+		}{
+			{1, parseInput1},
+			{2, parseInput2},
+		} {
+			t.Run(fmt.Sprintf("%s/%d", filename, tc.w), func(t *testing.T) {
+				m, err := tc.parse(filename)
+				require.NoError(t, err)
+				wh, err := parseWarehouse(filename, tc.w, 1)
+				require.NoError(t, err)
+				require.Equal(t, m.String(), wh.String())
+				for i, move := range m.moves {
+					want, err := m.Move(move)
+					require.NoError(t, err)
+					got, err := wh.Move(move)
+					require.NoError(t, err)
+					require.Equal(t, want, got, "move %d", i+1)
+					require.Equal(t, m.String(), wh.String(), "move %d", i+1)
+				}
+				assert.Equal(t, m.Sum(), wh.Sum())
+			})
+		}
+	}
+}
+
+func TestScaled(t *testing.T) {
+	m := &Map{
+		lines: [][]byte{
+			[]byte("#######"),
+			[]byte("#.....#"),
+			[]byte("#..O..#"),
+			[]byte("#.....#"),
+			[]byte("#######"),
+		},
+		rx: 2, ry: 2,
+		moves: []byte(">>v>>>>"),
+	}
+	wh, err := NewWarehouse(m, 2, 2)
+	require.NoError(t, err)
+	require.NoError(t, wh.Run())
+	// The robot pushes the top half of the box twice, then the bottom half
+	// until it hits the wall.
+	assert.Equal(t, `##############
+##############
+##..........##
+##..........##
+##........[]##
+##.......@[]##
+##..........##
+##..........##
+##############
+##############
+`, wh.String())
+	assert.Equal(t, 410, wh.Sum())
+	checkBoxes(t, wh)
+
+	wh, err = parseWarehouse("input.txt", 3, 2)
+	require.NoError(t, err)
+	boxes := len(wh.boxes)
+	require.NoError(t, wh.Run())
+	assert.Len(t, wh.boxes, boxes)
+	checkBoxes(t, wh)
+}
+
+func TestPushSet(t *testing.T) {
+	m := &Map{
+		lines: [][]byte{
+			[]byte("##########"),
+			[]byte("#........#"),
+			[]byte("#........#"),
+			[]byte("#........#"),
+			[]byte("#........#"),
+			[]byte("##########"),
+		},
+		rx: 5, ry: 4,
+	}
+	wh, err := NewWarehouse(m, 1, 1)
+	require.NoError(t, err)
+	// Two boxes three squares wide, which overlap by one square.
+	wh.add(Box{3, 2, 3, 1})
+	wh.add(Box{5, 3, 3, 1})
+	require.Equal(t, "##########\n#........#\n#..[=]...#\n#....[=].#\n#....@...#\n##########\n", wh.String())
+
+	ok, err := wh.Move('^')
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "##########\n#..[=]...#\n#....[=].#\n#....@...#\n#........#\n##########\n", wh.String())
+
+	// Now the top box is against the wall, so neither can move.
+	ok, err = wh.Move('^')
+	require.NoError(t, err)
+	assert.False(t, ok)
+	checkBoxes(t, wh)
+}
+
+// checkBoxes makes sure that no box overlaps a wall or another box.
+func checkBoxes(t *testing.T, wh *Warehouse) {
+	t.Helper()
+	seen := make(map[[2]int]bool)
+	for _, b := range wh.boxes {
+		for y := b.Y; y < b.Y+b.H; y++ {
+			for x := b.X; x < b.X+b.W; x++ {
+				assert.False(t, wh.blocked(x, y), "box %v is in a wall", b)
+				assert.False(t, seen[[2]int{x, y}], "box %v overlaps another box", b)
+				seen[[2]int{x, y}] = true
+			}
+		}
+	}
+}
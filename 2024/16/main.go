@@ -2,6 +2,9 @@ package main
 
 import (
 	"bufio"
+	"flag"
+	"fmt"
+	"log"
 	"os"
 	"strings"
 )
@@ -48,12 +51,14 @@ func (d direction) Turn() direction {
 }
 
 type node struct {
+	p       point
 	edges   [4]*node
 	end     bool
 	visited bool
 }
 
-func parseInput(filename string) (*node, *node, error) {
+// parseInput reads a maze, and returns the start tile and every end tile.
+func parseInput(filename string) (*node, []*node, error) {
 	file, err := os.Open(filename)
 	if err != nil {
 		return nil, nil, err
@@ -72,23 +77,23 @@ func parseInput(filename string) (*node, *node, error) {
 
 	// Build nodes
 	var start *node
-	var end *node
+	var ends []*node
 	var nodes [][]*node
-	for _, line := range lines {
+	for y, line := range lines {
 		var row []*node
-		for _, c := range line {
+		for x, c := range line {
 			var n *node
 			switch c {
 			case '#':
 				n = nil
 			case '.':
-				n = &node{}
+				n = &node{p: point{x, y}}
 			case 'S':
-				n = &node{}
+				n = &node{p: point{x, y}}
 				start = n
 			case 'E':
-				n = &node{end: true}
-				end = n
+				n = &node{p: point{x, y}, end: true}
+				ends = append(ends, n)
 			}
 			row = append(row, n)
 		}
@@ -111,7 +116,13 @@ func parseInput(filename string) (*node, *node, error) {
 			}
 		}
 	}
-	return start, end, nil
+	if start == nil {
+		return nil, nil, fmt.Errorf("%s: no start tile", filename)
+	}
+	if len(ends) == 0 {
+		return nil, nil, fmt.Errorf("%s: no end tile", filename)
+	}
+	return start, ends, nil
 }
 
 type key struct {
@@ -124,7 +135,10 @@ type cache map[key]int
 const moveCost = 1
 const turnCost = 1000
 
-func traverse(n *node, d direction, cost int, best cache) {
+// traverse finds the cheapest cost of reaching every tile facing every
+// direction, by depth first search. It counts the tiles it moves on from in
+// expanded.
+func traverse(n *node, d direction, cost int, best cache, expanded *int) {
 	if n.end {
 		key := key{n, N}
 		if c, ok := best[key]; !ok || c > cost {
@@ -137,35 +151,49 @@ func traverse(n *node, d direction, cost int, best cache) {
 		return
 	}
 	best[key] = cost
+	*expanded++
 	n2 := n.edges[d]
 	if n2 != nil {
-		traverse(n2, d, cost+moveCost, best)
+		traverse(n2, d, cost+moveCost, best, expanded)
 	}
 	d2 := d.Turn() // 90
 	n2 = n.edges[d2]
 	if n2 != nil {
-		traverse(n2, d2, cost+turnCost+moveCost, best)
+		traverse(n2, d2, cost+turnCost+moveCost, best, expanded)
 	}
 	d2 = d2.Turn() // 180
 	n2 = n.edges[d2]
 	if n2 != nil {
-		traverse(n2, d2, cost+turnCost+turnCost+moveCost, best)
+		traverse(n2, d2, cost+turnCost+turnCost+moveCost, best, expanded)
 	}
 	d2 = d2.Turn() // -90
 	n2 = n.edges[d2]
 	if n2 != nil {
-		traverse(n2, d2, cost+turnCost+moveCost, best)
+		traverse(n2, d2, cost+turnCost+moveCost, best, expanded)
 	}
 }
 
+// cheapest returns the lowest cost of reaching any of the ends.
+func cheapest(ends []*node, best cache) (int, bool) {
+	cost, found := 0, false
+	for _, end := range ends {
+		if c, ok := best[key{end, N}]; ok && (!found || c < cost) {
+			cost, found = c, true
+		}
+	}
+	return cost, found
+}
+
 func Part1(filename string) int {
-	start, end, err := parseInput(filename)
+	start, ends, err := parseInput(filename)
 	if err != nil {
 		panic(err)
 	}
 	best := make(cache)
-	traverse(start, E, 0, best)
-	return best[key{end, N}]
+	var expanded int
+	traverse(start, E, 0, best, &expanded)
+	cost, _ := cheapest(ends, best)
+	return cost
 }
 
 var visited = make(map[*node]bool)
@@ -210,15 +238,46 @@ func traverse2(n *node, d direction, steps []key, cost int, best cache) {
 }
 
 func Part2(filename string) int {
-	start, _, err := parseInput(filename)
+	start, ends, err := parseInput(filename)
 	if err != nil {
 		panic(err)
 	}
 	// Traverse once to find the best paths
 	best := make(cache)
-	traverse(start, E, 0, best)
+	var expanded int
+	traverse(start, E, 0, best, &expanded)
+	// Only the ends that are cheapest to reach count
+	cost, _ := cheapest(ends, best)
+	for _, end := range ends {
+		if best[key{end, N}] > cost {
+			delete(best, key{end, N})
+		}
+	}
 	// Traverse again to find the visited nodes
 	visited = make(map[*node]bool)
 	traverse2(start, E, nil, 0, best)
 	return len(visited)
 }
+
+func main() {
+	compare := flag.Bool("compare", false, "compare how many states each search algorithm expands")
+	k := flag.Int("k", 0, "print the costs of this many of the cheapest distinct paths")
+	flag.Parse()
+
+	filename := flag.Arg(0)
+	start, ends, err := parseInput(filename)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *compare {
+		if err := writeComparison(os.Stdout, start, ends); err != nil {
+			log.Fatal(err)
+		}
+	}
+	for i, p := range KBest(start, ends, *k) {
+		fmt.Printf("Path %d: cost %d, %d tiles\n", i+1, p.Cost, len(p.Steps))
+	}
+
+	fmt.Printf("Part 1: %d\n", Part1(filename))
+	fmt.Printf("Part 2: %d\n", Part2(filename))
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"container/heap"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// turns returns the number of quarter turns needed to go from facing one
+// direction to facing another.
+func turns(from, to direction) int {
+	for t := 0; ; t++ {
+		if from == to {
+			return min(t, 4-t)
+		}
+		from = from.Turn()
+	}
+}
+
+// minTurns returns the fewest quarter turns needed to get from p, facing d,
+// to q. Every direction that leads towards q has to be faced at some point.
+func minTurns(p point, d direction, q point) int {
+	var need []direction
+	switch {
+	case q.x > p.x:
+		need = append(need, E)
+	case q.x < p.x:
+		need = append(need, W)
+	}
+	switch {
+	case q.y > p.y:
+		need = append(need, S)
+	case q.y < p.y:
+		need = append(need, N)
+	}
+	switch len(need) {
+	case 0:
+		return 0
+	case 1:
+		return turns(d, need[0])
+	}
+	// Facing one of them, one turn gets to the other. Otherwise it takes
+	// a turn to face either one, and another to face the second.
+	if d == need[0] || d == need[1] {
+		return 1
+	}
+	return 2
+}
+
+// heuristic returns an estimate of the cost of getting from a tile to the
+// nearest end, that is never more than the real cost: each step costs at
+// least one move, and each change of direction at least one turn.
+func heuristic(ends []*node) func(key) int {
+	return func(k key) int {
+		h := -1
+		for _, end := range ends {
+			dx, dy := end.p.x-k.n.p.x, end.p.y-k.n.p.y
+			c := moveCost*(max(dx, -dx)+max(dy, -dy)) + turnCost*minTurns(k.n.p, k.d, end.p)
+			if h < 0 || c < h {
+				h = c
+			}
+		}
+		return h
+	}
+}
+
+// Path is a way through the maze. Steps holds each tile on the way, and the
+// direction faced on arriving there, from the start to an end.
+type Path struct {
+	Cost  int
+	Steps []key
+}
+
+// edge is a move out of a tile, in a given direction.
+type edge struct {
+	from key
+	d    direction
+}
+
+type item struct {
+	k        key
+	cost     int
+	priority int
+}
+
+// queue is a min-heap of items, ordered by priority.
+type queue []item
+
+func (q queue) Len() int           { return len(q) }
+func (q queue) Less(i, j int) bool { return q[i].priority < q[j].priority }
+func (q queue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+func (q *queue) Push(x any)        { *q = append(*q, x.(item)) }
+func (q *queue) Pop() any {
+	old := *q
+	it := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return it
+}
+
+// search finds the cheapest path from start to any end tile. The tiles in
+// blocked can't be entered, and the edges in cut can't be used. If h is nil,
+// it is Dijkstra's algorithm, otherwise it is A* with h as the heuristic. It
+// also returns the number of states expanded, and false if no end can be
+// reached.
+func search(start key, h func(key) int, blocked map[*node]bool, cut map[edge]bool) (Path, int, bool) {
+	if h == nil {
+		h = func(key) int { return 0 }
+	}
+	dist := map[key]int{start: 0}
+	parent := make(map[key]key)
+	closed := make(map[key]bool)
+	q := &queue{{start, 0, h(start)}}
+	var expanded int
+	for q.Len() > 0 {
+		it := heap.Pop(q).(item)
+		if closed[it.k] {
+			continue
+		}
+		closed[it.k] = true
+		expanded++
+		if it.k.n.end {
+			steps := []key{it.k}
+			for k := it.k; k != start; {
+				k = parent[k]
+				steps = append(steps, k)
+			}
+			slices.Reverse(steps)
+			return Path{it.cost, steps}, expanded, true
+		}
+		d := it.k.d
+		for t := range 4 {
+			n := it.k.n.edges[d]
+			if n != nil && !blocked[n] && !cut[edge{it.k, d}] {
+				next := key{n, d}
+				cost := it.cost + turnCost*min(t, 4-t) + moveCost
+				if c, ok := dist[next]; !ok || cost < c {
+					dist[next] = cost
+					parent[next] = it.k
+					heap.Push(q, item{next, cost, cost + h(next)})
+				}
+			}
+			d = d.Turn()
+		}
+	}
+	return Path{}, expanded, false
+}
+
+// stepCost returns the cost of moving from one step of a path to the next.
+func stepCost(from, to key) int {
+	return turnCost*turns(from.d, to.d) + moveCost
+}
+
+// KBest returns the k cheapest paths from start to any end, cheapest first,
+// using Yen's algorithm. No path visits a tile more than once, and no two
+// paths visit the same tiles in the same order.
+func KBest(start *node, ends []*node, k int) []Path {
+	h := heuristic(ends)
+	first, _, ok := search(key{start, E}, h, nil, nil)
+	if !ok || k < 1 {
+		return nil
+	}
+	best := []Path{first}
+	var candidates []Path
+	seen := func(steps []key) bool {
+		for _, p := range slices.Concat(best, candidates) {
+			if slices.Equal(p.Steps, steps) {
+				return true
+			}
+		}
+		return false
+	}
+	for len(best) < k {
+		prev := best[len(best)-1]
+		rootCost := 0
+		for i := 0; i < len(prev.Steps)-1; i++ {
+			if i > 0 {
+				rootCost += stepCost(prev.Steps[i-1], prev.Steps[i])
+			}
+			root := prev.Steps[:i+1]
+			spur := prev.Steps[i]
+
+			// Don't take any way out of the spur that an earlier path
+			// with the same root already took.
+			cut := make(map[edge]bool)
+			for _, p := range best {
+				if len(p.Steps) > i+1 && slices.Equal(p.Steps[:i+1], root) {
+					cut[edge{spur, p.Steps[i+1].d}] = true
+				}
+			}
+			// Don't go back through the root either.
+			blocked := make(map[*node]bool)
+			for _, s := range root {
+				blocked[s.n] = true
+			}
+			p, _, ok := search(spur, h, blocked, cut)
+			if !ok {
+				continue
+			}
+			steps := slices.Concat(root[:i], p.Steps)
+			if !seen(steps) {
+				candidates = append(candidates, Path{rootCost + p.Cost, steps})
+			}
+		}
+		if len(candidates) == 0 {
+			break
+		}
+		i := 0
+		for j, p := range candidates {
+			if p.Cost < candidates[i].Cost {
+				i = j
+			}
+		}
+		best = append(best, candidates[i])
+		candidates = slices.Delete(candidates, i, i+1)
+	}
+	return best
+}
+
+// Algorithm is a way of finding the cheapest path through a maze.
+type Algorithm struct {
+	Name string
+	// Find returns the cheapest cost of getting from start to any of the
+	// ends, and the number of states it expanded along the way.
+	Find func(start *node, ends []*node) (cost, expanded int, ok bool)
+}
+
+var Algorithms = []Algorithm{
+	{"DFS", func(start *node, ends []*node) (int, int, bool) {
+		best := make(cache)
+		var expanded int
+		traverse(start, E, 0, best, &expanded)
+		cost, ok := cheapest(ends, best)
+		return cost, expanded, ok
+	}},
+	{"Dijkstra", func(start *node, _ []*node) (int, int, bool) {
+		p, expanded, ok := search(key{start, E}, nil, nil, nil)
+		return p.Cost, expanded, ok
+	}},
+	{"A*", func(start *node, ends []*node) (int, int, bool) {
+		p, expanded, ok := search(key{start, E}, heuristic(ends), nil, nil)
+		return p.Cost, expanded, ok
+	}},
+}
+
+// writeComparison runs every algorithm on the maze, and prints the cost each
+// one found and how many states it expanded.
+func writeComparison(w io.Writer, start *node, ends []*node) error {
+	var s strings.Builder
+	for _, a := range Algorithms {
+		cost, expanded, ok := a.Find(start, ends)
+		if !ok {
+			fmt.Fprintf(&s, "%-8s  no path  %8d expanded\n", a.Name, expanded)
+			continue
+		}
+		fmt.Fprintf(&s, "%-8s %8d  %8d expanded\n", a.Name, cost, expanded)
+	}
+	_, err := io.WriteString(w, s.String())
+	return err
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMinTurns(t *testing.T) {
+	p := point{0, 0}
+	for _, tc := range []struct {
+		d        direction
+		q        point
+		expected int
+	}{
+		{E, point{0, 0}, 0},
+		{E, point{5, 0}, 0},
+		{N, point{5, 0}, 1},
+		{W, point{5, 0}, 2},
+		{E, point{5, 5}, 1},
+		{S, point{5, 5}, 1},
+		{W, point{5, 5}, 2},
+		{N, point{5, 5}, 2},
+		{N, point{-5, -5}, 1},
+	} {
+		assert.Equal(t, tc.expected, minTurns(p, tc.d, tc.q), "%v to %v", tc.d, tc.q)
+	}
+}
+
+func TestAlgorithms(t *testing.T) {
+	for _, tc := range []struct {
+		filename string
+		expected int
+	}{
+		{"test1a.txt", 7036},
+		{"test1b.txt", 11048},
+		{"input.txt", 72400},
+	} {
+		t.Run(tc.filename, func(t *testing.T) {
+			start, ends, err := parseInput(tc.filename)
+			require.NoError(t, err)
+			expanded := make(map[string]int)
+			for _, a := range Algorithms {
+				cost, n, ok := a.Find(start, ends)
+				require.True(t, ok, a.Name)
+				assert.Equal(t, tc.expected, cost, a.Name)
+				expanded[a.Name] = n
+			}
+			assert.Less(t, expanded["A*"], expanded["Dijkstra"])
+			assert.Less(t, expanded["Dijkstra"], expanded["DFS"])
+		})
+	}
+}
+
+func writeMaze(t *testing.T, maze string) string {
+	t.Helper()
+	name := filepath.Join(t.TempDir(), "maze.txt")
+	require.NoError(t, os.WriteFile(name, []byte(maze), 0o644))
+	return name
+}
+
+func TestEnds(t *testing.T) {
+	// The far end is closer as the crow flies, but the near one doesn't
+	// need any turns.
+	name := writeMaze(t, `#########
+#E#######
+#.#######
+#S.....E#
+#########
+`)
+	start, ends, err := parseInput(name)
+	require.NoError(t, err)
+	require.Len(t, ends, 2)
+	for _, a := range Algorithms {
+		cost, _, ok := a.Find(start, ends)
+		require.True(t, ok, a.Name)
+		assert.Equal(t, 6, cost, a.Name)
+	}
+	assert.Equal(t, 6, Part1(name))
+	assert.Equal(t, 7, Part2(name))
+
+	name = writeMaze(t, `#####
+#S#E#
+#####
+`)
+	start, ends, err = parseInput(name)
+	require.NoError(t, err)
+	for _, a := range Algorithms {
+		_, _, ok := a.Find(start, ends)
+		assert.False(t, ok, a.Name)
+	}
+	assert.Empty(t, KBest(start, ends, 3))
+
+	_, _, err = parseInput(writeMaze(t, "#S.#\n"))
+	assert.Error(t, err)
+}
+
+// checkPath makes sure that the path is a real way through the maze, that
+// doesn't visit any tile twice, and costs what it says.
+func checkPath(t *testing.T, start *node, p Path) {
+	t.Helper()
+	require.NotEmpty(t, p.Steps)
+	assert.Equal(t, key{start, E}, p.Steps[0])
+	assert.True(t, p.Steps[len(p.Steps)-1].n.end)
+	cost := 0
+	seen := map[*node]bool{start: true}
+	for i := 1; i < len(p.Steps); i++ {
+		prev, s := p.Steps[i-1], p.Steps[i]
+		assert.Same(t, s.n, prev.n.edges[s.d])
+		assert.False(t, seen[s.n], "tile %v visited twice", s.n.p)
+		seen[s.n] = true
+		cost += stepCost(prev, s)
+	}
+	assert.Equal(t, p.Cost, cost)
+}
+
+func TestKBest(t *testing.T) {
+	for _, tc := range []struct {
+		filename string
+		cheapest int
+		tiles    int
+	}{
+		{"test1a.txt", 7036, 45},
+		{"test1b.txt", 11048, 64},
+	} {
+		t.Run(tc.filename, func(t *testing.T) {
+			start, ends, err := parseInput(tc.filename)
+			require.NoError(t, err)
+			paths := KBest(start, ends, 20)
+			require.Len(t, paths, 20)
+			assert.Equal(t, tc.cheapest, paths[0].Cost)
+
+			tiles := make(map[*node]bool)
+			for i, p := range paths {
+				checkPath(t, start, p)
+				for _, q := range paths[:i] {
+					assert.LessOrEqual(t, q.Cost, p.Cost)
+					assert.False(t, slices.Equal(p.Steps, q.Steps), "path %d is the same as an earlier one", i+1)
+				}
+				if p.Cost == tc.cheapest {
+					for _, s := range p.Steps {
+						tiles[s.n] = true
+					}
+				}
+			}
+			// The tiles on all of the cheapest paths are the answer to
+			// Part 2.
+			assert.Equal(t, tc.tiles, len(tiles))
+		})
+	}
+}
@@ -0,0 +1,65 @@
+// Package checked provides integer arithmetic that reports overflow instead of
+// silently wrapping around.
+package checked
+
+import (
+	"errors"
+	"math"
+	"math/big"
+)
+
+var ErrOverflow = errors.New("integer overflow")
+
+// Add returns a + b, or ErrOverflow if the result does not fit in an int.
+func Add(a, b int) (int, error) {
+	c := a + b
+	if (c > a) != (b > 0) {
+		return 0, ErrOverflow
+	}
+	return c, nil
+}
+
+// Sub returns a - b, or ErrOverflow if the result does not fit in an int.
+func Sub(a, b int) (int, error) {
+	c := a - b
+	if (c < a) != (b > 0) {
+		return 0, ErrOverflow
+	}
+	return c, nil
+}
+
+// Mul returns a * b, or ErrOverflow if the result does not fit in an int.
+func Mul(a, b int) (int, error) {
+	if a == 0 || b == 0 {
+		return 0, nil
+	}
+	if (a == -1 && b == math.MinInt) || (b == -1 && a == math.MinInt) {
+		return 0, ErrOverflow
+	}
+	c := a * b
+	if c/b != a {
+		return 0, ErrOverflow
+	}
+	return c, nil
+}
+
+// Div returns a / b, or ErrOverflow if the result does not fit in an int.
+// Like the built-in operator, it panics if b is zero.
+func Div(a, b int) (int, error) {
+	if a == math.MinInt && b == -1 {
+		return 0, ErrOverflow
+	}
+	return a / b, nil
+}
+
+// Int converts x to an int, or returns ErrOverflow if it is out of range.
+func Int(x *big.Int) (int, error) {
+	if !x.IsInt64() {
+		return 0, ErrOverflow
+	}
+	v := x.Int64()
+	if int64(int(v)) != v {
+		return 0, ErrOverflow
+	}
+	return int(v), nil
+}
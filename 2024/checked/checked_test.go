@@ -0,0 +1,108 @@
+package checked
+
+import (
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// checkAgainstBig compares a checked result with the exact result computed
+// using math/big.
+func checkAgainstBig(t *testing.T, got int, err error, want *big.Int) {
+	t.Helper()
+	if want.IsInt64() && want.Int64() >= math.MinInt && want.Int64() <= math.MaxInt {
+		require.NoError(t, err)
+		assert.Equal(t, want.Int64(), int64(got))
+	} else {
+		assert.ErrorIs(t, err, ErrOverflow)
+	}
+}
+
+func TestOverflow(t *testing.T) {
+	_, err := Add(math.MaxInt, 1)
+	assert.ErrorIs(t, err, ErrOverflow)
+	_, err = Sub(math.MinInt, 1)
+	assert.ErrorIs(t, err, ErrOverflow)
+	_, err = Mul(math.MinInt, -1)
+	assert.ErrorIs(t, err, ErrOverflow)
+	_, err = Div(math.MinInt, -1)
+	assert.ErrorIs(t, err, ErrOverflow)
+	_, err = Mul(10000000000000, 10000000000000)
+	assert.ErrorIs(t, err, ErrOverflow)
+
+	v, err := Mul(509463489296712, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 5094634892967120, v)
+}
+
+func addSeeds(f *testing.F) {
+	for _, seed := range [][2]int{
+		{0, 0},
+		{1, -1},
+		{math.MaxInt, 1},
+		{math.MinInt, -1},
+		{math.MinInt, 0},
+		{-1, math.MinInt},
+		{10000000000000, 10000000000000},
+		{509463489296712, 1000},
+	} {
+		f.Add(seed[0], seed[1])
+	}
+}
+
+func FuzzAdd(f *testing.F) {
+	addSeeds(f)
+	f.Fuzz(func(t *testing.T, a, b int) {
+		got, err := Add(a, b)
+		want := new(big.Int).Add(big.NewInt(int64(a)), big.NewInt(int64(b)))
+		checkAgainstBig(t, got, err, want)
+	})
+}
+
+func FuzzSub(f *testing.F) {
+	addSeeds(f)
+	f.Fuzz(func(t *testing.T, a, b int) {
+		got, err := Sub(a, b)
+		want := new(big.Int).Sub(big.NewInt(int64(a)), big.NewInt(int64(b)))
+		checkAgainstBig(t, got, err, want)
+	})
+}
+
+func FuzzMul(f *testing.F) {
+	addSeeds(f)
+	f.Fuzz(func(t *testing.T, a, b int) {
+		got, err := Mul(a, b)
+		want := new(big.Int).Mul(big.NewInt(int64(a)), big.NewInt(int64(b)))
+		checkAgainstBig(t, got, err, want)
+	})
+}
+
+func FuzzDiv(f *testing.F) {
+	addSeeds(f)
+	f.Fuzz(func(t *testing.T, a, b int) {
+		if b == 0 {
+			t.Skip()
+		}
+		got, err := Div(a, b)
+		want := new(big.Int).Quo(big.NewInt(int64(a)), big.NewInt(int64(b)))
+		checkAgainstBig(t, got, err, want)
+	})
+}
+
+func FuzzInt(f *testing.F) {
+	f.Add("0")
+	f.Add("9223372036854775807")
+	f.Add("9223372036854775808")
+	f.Add("-9223372036854775809")
+	f.Fuzz(func(t *testing.T, s string) {
+		x, ok := new(big.Int).SetString(s, 10)
+		if !ok {
+			t.Skip()
+		}
+		got, err := Int(x)
+		checkAgainstBig(t, got, err, x)
+	})
+}